@@ -0,0 +1,83 @@
+package catalog
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// ServiceEnv is the subset of ManageHTTPServer capabilities a
+// CatalogServiceProvider needs in order to create and initialize a
+// service. It is defined here, rather than depending on the manageserver
+// package directly, to avoid an import cycle: manageserver imports
+// catalog to look up providers, and the individual catalog packages
+// (mongodb, redis, etc.) import catalog to register themselves.
+type ServiceEnv interface {
+	// CreateCommonService creates the service in the control plane and the
+	// container platform, and returns the assigned service uuid.
+	CreateCommonService(ctx context.Context, crReq interface{}, requuid string) (serviceUUID string, err error)
+
+	// ScheduleInitTask hands off the init task options to the manage
+	// server's background init task runner.
+	ScheduleInitTask(ctx context.Context, kind string, serviceUUID string, serviceName string, taskOpts interface{})
+
+	// SetServiceInitialized marks the service as initialized. Providers
+	// that do not require a background init task call this directly. The
+	// returned error is a manage/errors.Error carrying the HTTP status the
+	// caller should surface it with.
+	SetServiceInitialized(ctx context.Context, serviceName string, requuid string) error
+}
+
+// CatalogServiceProvider is implemented by each catalog service package
+// (mongodb, postgres, cassandra, kafka, redis, etc.) and registered via
+// the package's init() function. It lets manageserver create and
+// initialize any registered catalog service without a dedicated switch
+// case per service, so third parties can add new catalog services
+// (etcd, RabbitMQ, Neo4j, ...) without touching manageserver at all.
+type CatalogServiceProvider interface {
+	// Kind returns the catalog service type this provider handles, e.g.
+	// catalog.CatalogService_MongoDB.
+	Kind() string
+
+	// Decode reads and decodes the catalog-specific create request from
+	// the http request body.
+	Decode(r *http.Request) (req interface{}, err error)
+
+	// ValidateRequest validates the decoded create request.
+	ValidateRequest(req interface{}) error
+
+	// GenCreateRequest builds the generic service create request from the
+	// decoded, validated catalog-specific request.
+	GenCreateRequest(env ServiceEnv, req interface{}) (crReq interface{}, err error)
+
+	// RequiresInit reports whether the service needs a post-create init task.
+	RequiresInit(req interface{}) bool
+
+	// GenInitTask builds the init task options for the newly created
+	// service. Only called when RequiresInit returns true.
+	GenInitTask(env ServiceEnv, req interface{}, crReq interface{}, serviceUUID string, requuid string) (taskOpts interface{}, err error)
+
+	// ServiceNameOf returns the service name carried by a generic create
+	// request previously returned by GenCreateRequest.
+	ServiceNameOf(crReq interface{}) string
+
+	// CRToRequest adapts a Kubernetes custom resource (e.g. a
+	// v1alpha1.MongoDBService) into the same decoded request shape Decode
+	// would produce from an HTTP body, so the k8s operator controller can
+	// drive the identical create/init path as the REST API.
+	CRToRequest(cr interface{}) (req interface{})
+}
+
+var registry = make(map[string]CatalogServiceProvider)
+
+// Register registers a CatalogServiceProvider under its Kind(). Catalog
+// service packages call this from their init() function.
+func Register(p CatalogServiceProvider) {
+	registry[p.Kind()] = p
+}
+
+// Get returns the provider registered for the given catalog service kind,
+// or nil if no provider has registered for it yet.
+func Get(kind string) CatalogServiceProvider {
+	return registry[kind]
+}
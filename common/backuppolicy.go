@@ -0,0 +1,26 @@
+package common
+
+// BackupPolicy is the durable record of a service's recurring snapshot
+// schedule and its last run outcome, so a schedule and its history survive
+// a manage server restart.
+type BackupPolicy struct {
+	ServiceUUID string
+
+	// Schedule is a cron-like expression, e.g. "0 3 * * *" for daily at 3am.
+	Schedule string
+
+	S3Bucket string
+	S3Prefix string
+
+	// SSEKMSKeyID, when set, server-side encrypts the uploaded snapshot
+	// with the given KMS key instead of the bucket default.
+	SSEKMSKeyID string
+
+	// NextRunTime is the unix nanosecond timestamp StartBackupScheduler
+	// compares against to decide a schedule is due.
+	NextRunTime int64
+
+	LastRunTime int64
+	LastSuccess bool
+	LastError   string
+}
@@ -0,0 +1,28 @@
+package common
+
+// PendingInitTask is the durable record of a catalog service init task.
+// It is written before the task is handed to the in-memory init task
+// runner, and removed once the service is marked initialized, so a
+// manage server restart can find and resume any task that was still
+// running when the process crashed.
+type PendingInitTask struct {
+	ServiceUUID string
+	ServiceName string
+	ServiceType string
+
+	// TaskOpts carries the catalog-specific init task options, e.g. the
+	// value generated by mongodbcatalog.GenDefaultInitTaskRequest.
+	TaskOpts interface{}
+
+	// Attempts counts how many times this task has been (re)started.
+	Attempts int
+
+	// NextRetryTime is the unix nanosecond timestamp after which the task
+	// is eligible to be retried.
+	NextRetryTime int64
+
+	// LeaseExpireTime is the unix nanosecond timestamp after which the
+	// reaper considers the task abandoned and requeues it, even if
+	// Attempts/NextRetryTime would not otherwise trigger a retry.
+	LeaseExpireTime int64
+}
@@ -0,0 +1,39 @@
+package db
+
+import "github.com/cloudstax/firecamp/common"
+
+// NewPendingInitTask builds the PendingInitTask record that a DB's
+// CreatePendingInitTask method writes before an init task is handed to
+// the in-memory task runner, so the task survives a manage server crash
+// and can be resumed at startup. CreatePendingInitTask, ListPendingInitTasks
+// and DeletePendingInitTask are declared on the DB interface alongside
+// the other service record accessors; every backing implementation uses
+// this constructor to keep the zero-value fields consistent.
+func NewPendingInitTask(serviceUUID string, serviceName string, serviceType string, taskOpts interface{}) *common.PendingInitTask {
+	return &common.PendingInitTask{
+		ServiceUUID:     serviceUUID,
+		ServiceName:     serviceName,
+		ServiceType:     serviceType,
+		TaskOpts:        taskOpts,
+		Attempts:        0,
+		NextRetryTime:   0,
+		LeaseExpireTime: 0,
+	}
+}
+
+// UpdatePendingInitTaskRetry returns a copy of task with the attempts
+// counter bumped and the next retry time and lease set to the given
+// values. RecoverPendingInitTasks and the init-task reaper do not call it:
+// they requeue a journaled task as it is, with no backoff between tries.
+func UpdatePendingInitTaskRetry(task *common.PendingInitTask, nextRetryTime int64, leaseExpireTime int64) *common.PendingInitTask {
+	newTask := copyPendingInitTask(task)
+	newTask.Attempts = task.Attempts + 1
+	newTask.NextRetryTime = nextRetryTime
+	newTask.LeaseExpireTime = leaseExpireTime
+	return newTask
+}
+
+func copyPendingInitTask(task *common.PendingInitTask) *common.PendingInitTask {
+	newTask := *task
+	return &newTask
+}
@@ -0,0 +1,96 @@
+// Package v1alpha1 defines the firecamp.cloudstax.io CRD types. Each type
+// mirrors one of the catalog services exposed today through the manage
+// server's REST API (see manage/server/catalogservice.go), so a cluster
+// operator could provision the same services with `kubectl apply` instead
+// of the HTTP API. The types and their generated deep-copy methods are
+// complete, but applying one does not create anything yet: the k8s
+// operator controller (see k8s/operator) that would reconcile them is
+// scaffolding until a catalog/<kind> package registers a
+// CatalogServiceProvider.
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServicePhase reflects the lifecycle of the underlying catalog service,
+// mirroring common.ServiceStatus (creating/initializing/active).
+type ServicePhase string
+
+const (
+	ServicePhaseCreating     ServicePhase = "Creating"
+	ServicePhaseInitializing ServicePhase = "Initializing"
+	ServicePhaseActive       ServicePhase = "Active"
+	ServicePhaseFailed       ServicePhase = "Failed"
+)
+
+// CatalogServiceStatus is embedded in the .status of every firecamp CRD.
+type CatalogServiceStatus struct {
+	ServiceUUID string       `json:"serviceUUID,omitempty"`
+	Phase       ServicePhase `json:"phase,omitempty"`
+	Message     string       `json:"message,omitempty"`
+}
+
+// MongoDBService is the CRD for a firecamp-managed MongoDB replica set.
+type MongoDBService struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MongoDBServiceSpec   `json:"spec"`
+	Status CatalogServiceStatus `json:"status,omitempty"`
+}
+
+// MongoDBServiceSpec mirrors manage.CatalogCreateMongoDBRequest.Options.
+type MongoDBServiceSpec struct {
+	Replicas     int64  `json:"replicas"`
+	VolumeSizeGB int64  `json:"volumeSizeGB"`
+	Admin        string `json:"admin,omitempty"`
+	AdminPasswd  string `json:"adminPasswd,omitempty"`
+}
+
+// KafkaService is the CRD for a firecamp-managed Kafka cluster.
+type KafkaService struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KafkaServiceSpec     `json:"spec"`
+	Status CatalogServiceStatus `json:"status,omitempty"`
+}
+
+// KafkaServiceSpec mirrors manage.CatalogCreateKafkaRequest.Options.
+type KafkaServiceSpec struct {
+	Replicas      int64  `json:"replicas"`
+	VolumeSizeGB  int64  `json:"volumeSizeGB"`
+	ZkServiceName string `json:"zkServiceName"`
+}
+
+// RedisService is the CRD for a firecamp-managed Redis deployment.
+type RedisService struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RedisServiceSpec     `json:"spec"`
+	Status CatalogServiceStatus `json:"status,omitempty"`
+}
+
+// RedisServiceSpec mirrors manage.CatalogCreateRedisRequest.Options.
+type RedisServiceSpec struct {
+	Shards           int64 `json:"shards"`
+	ReplicasPerShard int64 `json:"replicasPerShard"`
+	VolumeSizeGB     int64 `json:"volumeSizeGB"`
+}
+
+// ConsulService is the CRD for a firecamp-managed Consul cluster.
+type ConsulService struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ConsulServiceSpec    `json:"spec"`
+	Status CatalogServiceStatus `json:"status,omitempty"`
+}
+
+// ConsulServiceSpec mirrors manage.CatalogCreateConsulRequest.Options.
+type ConsulServiceSpec struct {
+	Replicas     int64 `json:"replicas"`
+	VolumeSizeGB int64 `json:"volumeSizeGB"`
+}
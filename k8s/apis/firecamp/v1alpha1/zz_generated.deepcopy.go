@@ -0,0 +1,117 @@
+package v1alpha1
+
+import (
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto copies all fields into out.
+func (in *MongoDBService) DeepCopyInto(out *MongoDBService) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	out.Status = in.Status
+}
+
+// DeepCopy returns a new deep-copied MongoDBService.
+func (in *MongoDBService) DeepCopy() *MongoDBService {
+	if in == nil {
+		return nil
+	}
+	out := new(MongoDBService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject implements runtime.Object, required for the CRD to be
+// registered with the scheme and handed through a SharedIndexInformer.
+func (in *MongoDBService) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto copies all fields into out.
+func (in *KafkaService) DeepCopyInto(out *KafkaService) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	out.Status = in.Status
+}
+
+// DeepCopy returns a new deep-copied KafkaService.
+func (in *KafkaService) DeepCopy() *KafkaService {
+	if in == nil {
+		return nil
+	}
+	out := new(KafkaService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject implements runtime.Object, required for the CRD to be
+// registered with the scheme and handed through a SharedIndexInformer.
+func (in *KafkaService) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto copies all fields into out.
+func (in *RedisService) DeepCopyInto(out *RedisService) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	out.Status = in.Status
+}
+
+// DeepCopy returns a new deep-copied RedisService.
+func (in *RedisService) DeepCopy() *RedisService {
+	if in == nil {
+		return nil
+	}
+	out := new(RedisService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject implements runtime.Object, required for the CRD to be
+// registered with the scheme and handed through a SharedIndexInformer.
+func (in *RedisService) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto copies all fields into out.
+func (in *ConsulService) DeepCopyInto(out *ConsulService) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	out.Status = in.Status
+}
+
+// DeepCopy returns a new deep-copied ConsulService.
+func (in *ConsulService) DeepCopy() *ConsulService {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsulService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject implements runtime.Object, required for the CRD to be
+// registered with the scheme and handed through a SharedIndexInformer.
+func (in *ConsulService) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Package operator is scaffolding for a future firecamp Kubernetes
+// controller: it watches the firecamp.cloudstax.io CRDs and reconciles
+// them through the same CatalogServiceProvider path the manage server's
+// REST API uses (see catalog.Registry and
+// manageserver.ManageHTTPServer.CreateCommonService), so `kubectl apply`
+// and the HTTP API would stay behind one code path. It is not functional
+// yet: no catalog/<kind> package registers a CatalogServiceProvider in
+// this build (see opCatalogKind in manage/server/catalogservice.go), so
+// catalog.Get(rk.kind) is always nil and reconcile drops every CRD kind
+// (MongoDBService, KafkaService, RedisService, ConsulService) instead of
+// creating anything.
+package operator
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/cloudstax/firecamp/catalog"
+	manageserver "github.com/cloudstax/firecamp/manage/server"
+)
+
+// Controller reconciles firecamp CRDs into catalog services. One
+// Controller instance handles every registered catalog kind: the CRD
+// informers it starts are keyed by kind, and each enqueued key is
+// resolved to the catalog.CatalogServiceProvider for that kind.
+type Controller struct {
+	env     manageserver.CatalogServiceEnv
+	queue   workqueue.RateLimitingInterface
+	kindRef map[string]cache.SharedIndexInformer
+}
+
+// NewController creates a Controller that drives CR reconciliation through
+// env, the same ServiceEnv the manage server's catalog dispatch uses.
+func NewController(env manageserver.CatalogServiceEnv) *Controller {
+	return &Controller{
+		env:     env,
+		queue:   workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		kindRef: make(map[string]cache.SharedIndexInformer),
+	}
+}
+
+// AddInformer registers the shared informer for one CRD kind (e.g.
+// "MongoDBService") so its add/update events get queued for reconciliation.
+func (c *Controller) AddInformer(kind string, informer cache.SharedIndexInformer) {
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueue(kind, obj) },
+		UpdateFunc: func(old, new interface{}) { c.enqueue(kind, new) },
+	})
+	c.kindRef[kind] = informer
+}
+
+func (c *Controller) enqueue(kind string, obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		glog.Errorln("MetaNamespaceKeyFunc error", err, kind)
+		return
+	}
+	c.queue.Add(reconcileKey{kind: kind, key: key})
+}
+
+type reconcileKey struct {
+	kind string
+	key  string
+}
+
+// Run starts workers workers processing the reconcile queue until ctx is
+// canceled.
+func (c *Controller) Run(ctx context.Context, workers int) {
+	defer c.queue.ShutDown()
+
+	for i := 0; i < workers; i++ {
+		go c.runWorker(ctx)
+	}
+
+	<-ctx.Done()
+}
+
+func (c *Controller) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+func (c *Controller) processNextItem(ctx context.Context) bool {
+	item, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(item)
+
+	rk := item.(reconcileKey)
+	if err := c.reconcile(ctx, rk); err != nil {
+		if catalog.Get(rk.kind) == nil {
+			// no provider registered for this kind at all: retrying on the
+			// rate limiter's backoff will not help, since nothing will
+			// register one without an operator restart.
+			glog.Errorln("reconcile error, no provider registered, dropping", err, rk.kind, rk.key)
+			c.queue.Forget(item)
+			return true
+		}
+
+		glog.Errorln("reconcile error", err, rk.kind, rk.key)
+		c.queue.AddRateLimited(item)
+		return true
+	}
+
+	c.queue.Forget(item)
+	return true
+}
+
+// reconcile looks up the CatalogServiceProvider registered for rk.kind and
+// drives it through the create/init path. It does not write the resulting
+// serviceUUID and phase back to the CR's .status: that needs an
+// Update/UpdateStatus call through the generated firecamp clientset, which
+// this package does not have a reference to. Until a clientset is wired
+// in, a CR's .status stays whatever was last applied and the authoritative
+// state lives in the manage server's db, the same as for services created
+// through the REST API.
+func (c *Controller) reconcile(ctx context.Context, rk reconcileKey) error {
+	provider := catalog.Get(rk.kind)
+	if provider == nil {
+		return fmt.Errorf("no CatalogServiceProvider registered for kind %s", rk.kind)
+	}
+
+	informer, ok := c.kindRef[rk.kind]
+	if !ok {
+		return fmt.Errorf("no informer registered for kind %s", rk.kind)
+	}
+
+	obj, exists, err := informer.GetIndexer().GetByKey(rk.key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		// the CR was deleted; the container services it created are left
+		// in place, matching the manage server's own deleteService being a
+		// separate, explicit operation.
+		return nil
+	}
+
+	return c.reconcileService(ctx, provider, obj)
+}
+
+// reconcileService creates and initializes the catalog service behind a
+// CR, reusing manageserver's CatalogServiceProvider flow so a reconcile
+// triggered by kubectl apply takes the identical path as the REST API. A
+// requeue after a transient error re-runs this from the top, so it relies
+// on CreateCommonService rejecting (rather than duplicating) a service
+// name that already exists, the same guarantee the REST create handlers
+// depend on.
+func (c *Controller) reconcileService(ctx context.Context, provider catalog.CatalogServiceProvider, cr interface{}) error {
+	req := provider.CRToRequest(cr)
+
+	err := provider.ValidateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	crReq, err := provider.GenCreateRequest(c.env, req)
+	if err != nil {
+		return err
+	}
+
+	serviceUUID, err := c.env.CreateCommonService(ctx, crReq, "k8s-operator")
+	if err != nil {
+		return err
+	}
+
+	if !provider.RequiresInit(req) {
+		return c.env.SetServiceInitialized(ctx, provider.ServiceNameOf(crReq), "k8s-operator")
+	}
+
+	taskOpts, err := provider.GenInitTask(c.env, req, crReq, serviceUUID, "k8s-operator")
+	if err != nil {
+		return err
+	}
+
+	c.env.ScheduleInitTask(ctx, provider.Kind(), serviceUUID, provider.ServiceNameOf(crReq), taskOpts)
+	return nil
+}
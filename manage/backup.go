@@ -0,0 +1,59 @@
+package manage
+
+// CatalogBackupPolicyRequest schedules recurring snapshots of a catalog
+// service to S3, e.g. mongodump, nodetool snapshot, BGSAVE, or an
+// Elasticsearch snapshot, depending on the service's catalog kind.
+type CatalogBackupPolicyRequest struct {
+	Service *ServiceCommonRequest
+
+	// Schedule is a cron-like expression, e.g. "0 3 * * *" for daily at 3am.
+	Schedule string
+
+	S3Bucket string
+	S3Prefix string
+
+	// SSEKMSKeyID, when set, server-side encrypts the uploaded snapshot
+	// with the given KMS key instead of the bucket default.
+	SSEKMSKeyID string
+}
+
+// CatalogRestoreRequest provisions a new service with the same catalog
+// options as the backed-up one, and pre-seeds every member's volume from
+// the manifest recorded at backup time.
+type CatalogRestoreRequest struct {
+	Service *ServiceCommonRequest
+
+	// BackupServiceUUID is the service the snapshot manifest was created
+	// for. SnapshotID selects which snapshot under that service to restore;
+	// the empty string means the latest successful snapshot.
+	BackupServiceUUID string
+	SnapshotID         string
+}
+
+// CatalogBackupStatusRequest asks for the current backup schedule state
+// of a service.
+type CatalogBackupStatusRequest struct {
+	Service *ServiceCommonRequest
+}
+
+// CatalogBackupStatusResponse reports the last-run outcome and the next
+// scheduled run, recovered from the db so it survives a manage server
+// restart.
+type CatalogBackupStatusResponse struct {
+	Schedule     string
+	LastRunTime  int64
+	LastSuccess  bool
+	LastError    string
+	NextRunTime  int64
+}
+
+// BackupManifest describes one snapshot uploaded to S3: enough to restore
+// a member's volume, and to verify the upload was not corrupted.
+type BackupManifest struct {
+	ServiceUUID       string
+	MemberName        string
+	ConfigFileVersion int64
+	S3Key             string
+	Checksum          string
+	CreatedTime       int64
+}
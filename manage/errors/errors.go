@@ -0,0 +1,95 @@
+// Package errors defines the typed errors the manage HTTP API returns to
+// clients, modeled on the Docker and Kubernetes API error conventions: a
+// stable machine-readable Code, a human-readable message, the HTTP Status
+// it maps to, and, for ValidationError, which request fields failed and
+// why. manage.ConvertToHTTPError maps a lower-layer (db/containersvc/dns)
+// error to one of these, and manageserver.writeError serializes whichever
+// one a handler returns as the response body.
+package errors
+
+import "net/http"
+
+// FieldError describes why a single request field failed validation, e.g.
+// an invalid IPv4/IPv6 address in a service endpoint's IPAM config.
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// Error is the interface every typed manage API error satisfies. Status is
+// the HTTP status the error maps to; Code is the stable string a client can
+// switch on without parsing Error().
+type Error interface {
+	error
+	Code() string
+	Status() int
+}
+
+type apiError struct {
+	code    string
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string { return e.message }
+func (e *apiError) Code() string  { return e.code }
+func (e *apiError) Status() int   { return e.status }
+
+// BadRequest is returned for a malformed or self-inconsistent request, e.g.
+// a body that fails to decode or a cluster/region mismatch.
+func BadRequest(message string) Error {
+	return &apiError{code: "BadRequest", status: http.StatusBadRequest, message: message}
+}
+
+// NotFound is returned when the referenced service, member, template or
+// operation does not exist.
+func NotFound(message string) Error {
+	return &apiError{code: "NotFound", status: http.StatusNotFound, message: message}
+}
+
+// Unauthorized is returned when the Authenticator rejects the request.
+func Unauthorized(message string) Error {
+	return &apiError{code: "Unauthorized", status: http.StatusUnauthorized, message: message}
+}
+
+// Forbidden is returned when the authenticated principal is not authorized
+// for the requested method/resource.
+func Forbidden(message string) Error {
+	return &apiError{code: "Forbidden", status: http.StatusForbidden, message: message}
+}
+
+// Conflict is returned when a request loses a race with another update to
+// the same record, e.g. updateMemberConfigWithRetry exhausting its retries.
+func Conflict(message string) Error {
+	return &apiError{code: "Conflict", status: http.StatusConflict, message: message}
+}
+
+// Internal is returned for a failure on our side, e.g. marshaling a
+// response or an unrecognized db/containersvc/dns error.
+func Internal(message string) Error {
+	return &apiError{code: "InternalError", status: http.StatusInternalServerError, message: message}
+}
+
+// New builds an Error with an arbitrary code/status, for the rare case
+// (e.g. an unsupported HTTP method) that does not fit BadRequest, NotFound,
+// Conflict or Internal.
+func New(code string, status int, message string) Error {
+	return &apiError{code: code, status: status, message: message}
+}
+
+// ValidationError reports one or more invalid request fields, e.g. an
+// invalid IPv4/IPv6 address in a service endpoint's IPAM config. Details is
+// surfaced in the response body so a client can tell which field to fix
+// without parsing Message.
+type ValidationError struct {
+	apiError
+	Details []FieldError
+}
+
+// Validation returns a ValidationError carrying the given field details.
+func Validation(message string, details ...FieldError) *ValidationError {
+	return &ValidationError{
+		apiError: apiError{code: "ValidationError", status: http.StatusBadRequest, message: message},
+		Details:  details,
+	}
+}
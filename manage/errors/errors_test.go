@@ -0,0 +1,67 @@
+package errors
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestConstructorsSetCodeStatusAndMessage(t *testing.T) {
+	cases := []struct {
+		err         Error
+		wantCode    string
+		wantStatus  int
+		wantMessage string
+	}{
+		{BadRequest("bad"), "BadRequest", http.StatusBadRequest, "bad"},
+		{NotFound("missing"), "NotFound", http.StatusNotFound, "missing"},
+		{Unauthorized("nope"), "Unauthorized", http.StatusUnauthorized, "nope"},
+		{Forbidden("no"), "Forbidden", http.StatusForbidden, "no"},
+		{Conflict("race"), "Conflict", http.StatusConflict, "race"},
+		{Internal("boom"), "InternalError", http.StatusInternalServerError, "boom"},
+	}
+
+	for _, c := range cases {
+		if c.err.Code() != c.wantCode {
+			t.Errorf("Code() = %q, want %q", c.err.Code(), c.wantCode)
+		}
+		if c.err.Status() != c.wantStatus {
+			t.Errorf("Status() = %d, want %d", c.err.Status(), c.wantStatus)
+		}
+		if c.err.Error() != c.wantMessage {
+			t.Errorf("Error() = %q, want %q", c.err.Error(), c.wantMessage)
+		}
+	}
+}
+
+func TestNewBuildsArbitraryCodeAndStatus(t *testing.T) {
+	err := New("NotImplemented", http.StatusNotImplemented, "not supported")
+	if err.Code() != "NotImplemented" {
+		t.Errorf("Code() = %q, want %q", err.Code(), "NotImplemented")
+	}
+	if err.Status() != http.StatusNotImplemented {
+		t.Errorf("Status() = %d, want %d", err.Status(), http.StatusNotImplemented)
+	}
+}
+
+func TestValidationCarriesFieldDetails(t *testing.T) {
+	details := []FieldError{
+		{Field: "StaticIP", Reason: "required"},
+		{Field: "Port", Reason: "must be positive"},
+	}
+	verr := Validation("invalid request", details...)
+
+	if verr.Code() != "ValidationError" {
+		t.Errorf("Code() = %q, want %q", verr.Code(), "ValidationError")
+	}
+	if verr.Status() != http.StatusBadRequest {
+		t.Errorf("Status() = %d, want %d", verr.Status(), http.StatusBadRequest)
+	}
+	if len(verr.Details) != len(details) {
+		t.Fatalf("expected %d field details, got %d", len(details), len(verr.Details))
+	}
+	for i, d := range details {
+		if verr.Details[i] != d {
+			t.Errorf("Details[%d] = %+v, want %+v", i, verr.Details[i], d)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package manage
+
+import (
+	"strings"
+
+	merrors "github.com/cloudstax/firecamp/manage/errors"
+)
+
+// ConvertToHTTPError maps an error returned by the db, containersvc or dns
+// layers to the typed manage API error manageserver.writeError should
+// return to the caller. None of those layers export sentinel error types,
+// so this matches on the error message the same way callers already had to
+// before this existed; a layer that starts returning a typed error can add
+// a case above the fallback.
+func ConvertToHTTPError(err error) merrors.Error {
+	if err == nil {
+		return nil
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"), strings.Contains(msg, "NotFound"):
+		return merrors.NotFound(msg)
+	case strings.Contains(msg, "already exist"), strings.Contains(msg, "Conflict"), strings.Contains(msg, "conflict"):
+		return merrors.Conflict(msg)
+	default:
+		return merrors.Internal(msg)
+	}
+}
@@ -0,0 +1,65 @@
+package manage
+
+import "github.com/cloudstax/firecamp/operations"
+
+// GetOperationRequest asks for the current state of one async operation,
+// e.g. one started by createService, deleteService, runTask or
+// DeployTemplateOp.
+type GetOperationRequest struct {
+	Region      string
+	Cluster     string
+	OperationID string
+}
+
+// GetOperationResponse returns the Operation's current state.
+type GetOperationResponse struct {
+	Operation *operations.Operation
+}
+
+// ListOperationsRequest lists every async operation recorded for the
+// cluster.
+type ListOperationsRequest struct {
+	Region  string
+	Cluster string
+}
+
+// ListOperationsResponse returns every tracked Operation for the cluster.
+type ListOperationsResponse struct {
+	Operations []*operations.Operation
+}
+
+// CancelOperationRequest cancels a still in-flight async operation. It is
+// a no-op, not an error, if the operation already finished.
+type CancelOperationRequest struct {
+	Region      string
+	Cluster     string
+	OperationID string
+}
+
+// WaitOperationRequest long-polls for an async operation to reach a
+// terminal status, for callers that do not want to poll GetOperationOp
+// themselves. TimeoutSeconds bounds how long the call blocks; 0 means use
+// the manage server's default.
+type WaitOperationRequest struct {
+	Region         string
+	Cluster        string
+	OperationID    string
+	TimeoutSeconds int64
+}
+
+// WaitOperationResponse returns the Operation's state once it reached a
+// terminal status, or its last known state if TimeoutSeconds elapsed
+// first.
+type WaitOperationResponse struct {
+	Operation *operations.Operation
+}
+
+// GetOperationOp, ListOperationsOp, CancelOperationOp and WaitOperationOp
+// are the special ops ManageHTTPServer dispatches to the operation
+// tracking handlers.
+const (
+	GetOperationOp    = SpecialOpPrefix + "GetOperation"
+	ListOperationsOp  = SpecialOpPrefix + "ListOperations"
+	CancelOperationOp = SpecialOpPrefix + "CancelOperation"
+	WaitOperationOp   = SpecialOpPrefix + "WaitOperation"
+)
@@ -0,0 +1,21 @@
+package manage
+
+// RollingRestartFailurePolicy controls what a rolling restart does when a
+// member fails to come back healthy.
+type RollingRestartFailurePolicy string
+
+const (
+	// RollingRestartAbortOnFailure stops the rolling restart as soon as a
+	// member fails to become ready, leaving the remaining members untouched.
+	RollingRestartAbortOnFailure RollingRestartFailurePolicy = "abort"
+
+	// RollingRestartContinuePastFailures keeps restarting the remaining
+	// members even if up to MaxFailures members failed to
+	// become ready.
+	RollingRestartContinuePastFailures RollingRestartFailurePolicy = "continue"
+)
+
+// DefaultRollingRestartMaxFailures is used when
+// CatalogSetServiceInitRequest.FailurePolicy is RollingRestartContinuePastFailures
+// and MaxFailures is left unset (zero).
+const DefaultRollingRestartMaxFailures = 1
@@ -0,0 +1,82 @@
+package manage
+
+// RolloutType selects how UpdateServiceOp shifts traffic from the stable
+// service to the new one.
+type RolloutType string
+
+const (
+	// RolloutRecreate replaces the stable service in place; there is no
+	// separate canary service and no traffic shifting.
+	RolloutRecreate RolloutType = "Recreate"
+	// RolloutBlueGreen registers the new service alongside the stable one
+	// and cuts traffic over to it in a single step on PromoteServiceOp.
+	RolloutBlueGreen RolloutType = "BlueGreen"
+	// RolloutCanary registers the new service alongside the stable one and
+	// shifts traffic to it gradually, one Steps entry at a time.
+	RolloutCanary RolloutType = "Canary"
+)
+
+// RolloutStep is one step of a Canary rollout: Weight is the percentage of
+// traffic (0-100) the canary service should receive once this step starts,
+// and Pause is how long to hold at that weight before advancing to the
+// next step, e.g. "5m". An empty Pause holds until PromoteServiceOp or
+// AbortServiceOp is called explicitly.
+type RolloutStep struct {
+	Weight int
+	Pause  string
+}
+
+// RolloutStrategy describes how UpdateServiceOp should roll out a new
+// service revision. StableServiceSuffix and CanaryServiceSuffix name the
+// two backing container services registered under the service's DNS
+// domain, e.g. "-stable" and "-canary", so the failover binder and other
+// clients can tell them apart.
+type RolloutStrategy struct {
+	Type                RolloutType
+	Steps               []RolloutStep
+	StableServiceSuffix string
+	CanaryServiceSuffix string
+}
+
+// UpdateServiceRequest starts rolling a new container image out to an
+// existing service, following Rollout. Only RolloutRecreate (or a nil
+// Rollout, which defaults to it) is implemented: it updates the service's
+// image in place. RolloutBlueGreen and RolloutCanary need a DNS provider
+// that supports weighted records to split traffic between the stable and
+// canary backing services, which this build does not have, so
+// UpdateServiceOp rejects them instead of accepting a rollout it cannot
+// carry out.
+type UpdateServiceRequest struct {
+	Service        *ServiceCommonRequest
+	ContainerImage string
+	Rollout        *RolloutStrategy
+}
+
+// PromoteServiceRequest would flip the service's active backing service
+// from stable to canary, immediately for a BlueGreen rollout or by
+// jumping straight to 100% weight for a Canary rollout still in
+// progress. In this build PromoteServiceOp always fails with a BadRequest
+// error: since UpdateServiceOp rejects RolloutBlueGreen and RolloutCanary
+// outright (see UpdateServiceRequest), no rollout can ever be in progress
+// to promote.
+type PromoteServiceRequest struct {
+	Service *ServiceCommonRequest
+}
+
+// AbortServiceRequest would roll the service's weight back to the stable
+// backing service and stop any in-progress rollout, leaving the canary
+// service registered but unreachable so the operator can inspect it. In
+// this build AbortServiceOp always fails with a BadRequest error for the
+// same reason as PromoteServiceOp: no rollout can ever be in progress to
+// abort.
+type AbortServiceRequest struct {
+	Service *ServiceCommonRequest
+}
+
+// UpdateServiceOp, PromoteServiceOp and AbortServiceOp are the special ops
+// ManageHTTPServer dispatches to the rollout handlers.
+const (
+	UpdateServiceOp  = SpecialOpPrefix + "UpdateService"
+	PromoteServiceOp = SpecialOpPrefix + "PromoteService"
+	AbortServiceOp   = SpecialOpPrefix + "AbortService"
+)
@@ -0,0 +1,210 @@
+package manageserver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/cloudstax/firecamp/manage"
+)
+
+// Role is a named set of permissions a Principal may hold. Roles are
+// additive: admin can do everything operator can, and operator everything
+// viewer can.
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+// Principal identifies the caller ServeHTTP is acting on behalf of, as
+// established by the configured Authenticator.
+type Principal struct {
+	Name  string
+	Roles []Role
+}
+
+func (p *Principal) hasRole(required Role) bool {
+	for _, r := range p.Roles {
+		switch {
+		case r == required:
+			return true
+		case r == RoleAdmin:
+			return true
+		case r == RoleOperator && required == RoleViewer:
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnauthenticated is returned by an Authenticator when the request
+// carries no usable or valid credential.
+var ErrUnauthenticated = fmt.Errorf("request is not authenticated")
+
+// Authenticator validates an incoming request and identifies the caller.
+// ManageHTTPServer is agnostic to how: TLSClientCertAuthenticator and
+// HMACAuthenticator below cover mTLS and shared-secret deployments, and an
+// OIDC deployment plugs in by wrapping a TokenValidator in
+// OIDCAuthenticator.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// TLSClientCertAuthenticator authenticates callers connecting with a
+// client certificate (e.g. over mTLS), using the leaf certificate's
+// Subject.CommonName as the principal name. RoleOf maps a CommonName to
+// its roles; a CommonName absent from RoleOf authenticates with no roles
+// and is rejected by authorize.
+type TLSClientCertAuthenticator struct {
+	RoleOf map[string][]Role
+}
+
+// Authenticate implements Authenticator.
+func (a *TLSClientCertAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, ErrUnauthenticated
+	}
+
+	cn := r.TLS.PeerCertificates[0].Subject.CommonName
+	return &Principal{Name: cn, Roles: a.RoleOf[cn]}, nil
+}
+
+// HMACAuthenticator authenticates requests signed with a per-principal
+// shared secret: the caller sends the "X-Principal" header naming itself,
+// and an "X-Signature" header with the hex HMAC-SHA256 of
+// "<method> <request-uri> <principal>", keyed by that principal's secret.
+type HMACAuthenticator struct {
+	// SecretOf looks up the shared secret and roles for a principal name.
+	// ok is false if the principal is unknown.
+	SecretOf func(name string) (secret string, roles []Role, ok bool)
+}
+
+// Authenticate implements Authenticator.
+func (a *HMACAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	name := r.Header.Get("X-Principal")
+	sig := r.Header.Get("X-Signature")
+	if len(name) == 0 || len(sig) == 0 {
+		return nil, ErrUnauthenticated
+	}
+
+	secret, roles, ok := a.SecretOf(name)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s %s %s", r.Method, r.URL.RequestURI(), name)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return nil, ErrUnauthenticated
+	}
+
+	return &Principal{Name: name, Roles: roles}, nil
+}
+
+// TokenValidator verifies a bearer token, e.g. against an OIDC provider's
+// JWKS endpoint, and returns the principal it identifies. Kept as a
+// function type so ManageHTTPServer does not need to depend on a specific
+// OIDC library.
+type TokenValidator func(token string) (*Principal, error)
+
+// OIDCAuthenticator authenticates requests carrying an
+// "Authorization: Bearer <token>" header, delegating the token
+// verification itself to Validate.
+type OIDCAuthenticator struct {
+	Validate TokenValidator
+}
+
+// Authenticate implements Authenticator.
+func (a *OIDCAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, ErrUnauthenticated
+	}
+	return a.Validate(strings.TrimPrefix(auth, "Bearer "))
+}
+
+// opRoles raises the minimum role required for a special op above the
+// method-based default in authorize. setServiceInitialized forces a
+// service out of initializing state, and the rollout ops shift or cut over
+// live traffic, so all four are restricted to admin rather than falling
+// back to the operator default every other POST/PUT op gets.
+var opRoles = map[string]Role{
+	manage.ServiceInitializedOp: RoleAdmin,
+	manage.UpdateServiceOp:      RoleAdmin,
+	manage.PromoteServiceOp:     RoleAdmin,
+	manage.AbortServiceOp:       RoleAdmin,
+}
+
+// authorize reports whether principal may perform the operation at
+// trimURL via method. list*/get* (always GET) default to viewer;
+// create/delete/runTask (POST, PUT, DELETE) default to operator; entries
+// in opRoles override the default for operations that need more.
+func authorize(principal *Principal, method string, trimURL string) bool {
+	required, ok := opRoles[trimURL]
+	if !ok {
+		required = RoleOperator
+		if method == http.MethodGet {
+			required = RoleViewer
+		}
+	}
+
+	if !principal.hasRole(required) {
+		glog.Errorln("principal", principal.Name, "roles", principal.Roles,
+			"not authorized for", method, trimURL, "requires", required)
+		return false
+	}
+
+	return true
+}
+
+// AuditDecision is the outcome ServeHTTP recorded for an audited request.
+type AuditDecision string
+
+const (
+	AuditAllowed         AuditDecision = "allowed"
+	AuditUnauthenticated AuditDecision = "unauthenticated"
+	AuditUnauthorized    AuditDecision = "unauthorized"
+)
+
+// AuditRecord describes one ServeHTTP call, for AuditSink to ship to a SIEM
+// or other audit store.
+type AuditRecord struct {
+	Requuid   string
+	Principal string
+	Method    string
+	TrimURL   string
+	Service   string
+	Decision  AuditDecision
+	Latency   time.Duration
+}
+
+// AuditSink receives one AuditRecord per ServeHTTP call. Implementations
+// should not block ServeHTTP for long; a sink shipping to a remote SIEM
+// should buffer or do so asynchronously itself.
+type AuditSink interface {
+	Audit(record AuditRecord)
+}
+
+// GlogAuditSink is the default AuditSink, logging each record as a single
+// glog line. It is used when ManageHTTPServer is not given a more specific
+// sink, so audit records are always captured somewhere even if operators
+// have not yet wired up a SIEM shipper.
+type GlogAuditSink struct{}
+
+// Audit implements AuditSink.
+func (s *GlogAuditSink) Audit(record AuditRecord) {
+	glog.Infoln("audit", "requuid", record.Requuid, "principal", record.Principal,
+		"method", record.Method, "url", record.TrimURL, "service", record.Service,
+		"decision", record.Decision, "latency", record.Latency)
+}
@@ -0,0 +1,53 @@
+package manageserver
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/cloudstax/firecamp/manage"
+)
+
+func TestAuthorizeDefaultsByMethod(t *testing.T) {
+	viewer := &Principal{Name: "v", Roles: []Role{RoleViewer}}
+	if !authorize(viewer, http.MethodGet, "/listServices") {
+		t.Error("expected a viewer to be authorized for a GET op")
+	}
+	if authorize(viewer, http.MethodPost, "/createService") {
+		t.Error("expected a viewer not to be authorized for a POST op")
+	}
+
+	operator := &Principal{Name: "o", Roles: []Role{RoleOperator}}
+	if !authorize(operator, http.MethodPost, "/createService") {
+		t.Error("expected an operator to be authorized for a POST op")
+	}
+	if !authorize(operator, http.MethodGet, "/listServices") {
+		t.Error("expected an operator to also be authorized for a GET op")
+	}
+}
+
+func TestAuthorizeOpRolesOverrideRequireAdmin(t *testing.T) {
+	operator := &Principal{Name: "o", Roles: []Role{RoleOperator}}
+	if authorize(operator, http.MethodPost, manage.UpdateServiceOp) {
+		t.Error("expected an operator not to be authorized for an admin-only op")
+	}
+
+	admin := &Principal{Name: "a", Roles: []Role{RoleAdmin}}
+	if !authorize(admin, http.MethodPost, manage.UpdateServiceOp) {
+		t.Error("expected an admin to be authorized for an admin-only op")
+	}
+}
+
+func TestPrincipalHasRoleHierarchy(t *testing.T) {
+	p := &Principal{Roles: []Role{RoleOperator}}
+	if !p.hasRole(RoleViewer) {
+		t.Error("expected operator to satisfy a viewer requirement")
+	}
+	if p.hasRole(RoleAdmin) {
+		t.Error("expected operator not to satisfy an admin requirement")
+	}
+
+	admin := &Principal{Roles: []Role{RoleAdmin}}
+	if !admin.hasRole(RoleOperator) || !admin.hasRole(RoleViewer) {
+		t.Error("expected admin to satisfy every lower requirement")
+	}
+}
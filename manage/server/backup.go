@@ -0,0 +1,244 @@
+package manageserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/cloudstax/firecamp/catalog"
+	"github.com/cloudstax/firecamp/common"
+	"github.com/cloudstax/firecamp/containersvc"
+	"github.com/cloudstax/firecamp/manage"
+	merrors "github.com/cloudstax/firecamp/manage/errors"
+)
+
+// backupSnapshotCmd builds the catalog-specific snapshot command for one
+// member, e.g. mongodump for MongoDB, nodetool snapshot + sstable upload
+// for Cassandra, BGSAVE + rdb upload for Redis, or the Elasticsearch
+// snapshot API. cmd is the argv the container image's entrypoint runs for
+// TaskTypeBackup; envkvs carries it (and the destination bucket/prefix)
+// into the task's environment, the same handoff createCommonService uses
+// for other task types. Each catalog package is meant to register its
+// command builder in backupSnapshotCmds from its own init() function, the
+// same way catalog service create providers register into
+// catalog.Registry, but none do yet in this build - runBackup below
+// reports "no backup snapshot command registered" instead of silently
+// skipping a service it cannot back up.
+type backupSnapshotCmd func(member *common.ServiceMember, s3Bucket string, s3Prefix string) (cmd []string, envkvs []*common.EnvKeyValuePair)
+
+var backupSnapshotCmds = make(map[string]backupSnapshotCmd)
+
+// RegisterBackupSnapshotCmd registers the snapshot command builder for a
+// catalog service kind.
+func RegisterBackupSnapshotCmd(kind string, cmd backupSnapshotCmd) {
+	backupSnapshotCmds[kind] = cmd
+}
+
+// setBackupPolicy schedules recurring per-member snapshot jobs for a
+// service, recording the schedule in the db so it survives a manage
+// server restart.
+func (s *ManageHTTPServer) setBackupPolicy(ctx context.Context, r *http.Request, requuid string) merrors.Error {
+	req := &manage.CatalogBackupPolicyRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		glog.Errorln("CatalogBackupPolicyRequest decode error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
+		glog.Errorln("CatalogBackupPolicyRequest invalid request, local cluster", s.cluster,
+			"region", s.region, "requuid", requuid, req.Service)
+		return merrors.BadRequest("cluster or region mismatch")
+	}
+
+	svc, err := s.dbIns.GetService(ctx, s.cluster, req.Service.ServiceName)
+	if err != nil {
+		glog.Errorln("GetService error", err, "requuid", requuid, req.Service)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	err = s.dbIns.SetBackupPolicy(ctx, svc.ServiceUUID, req.Schedule, req.S3Bucket, req.S3Prefix, req.SSEKMSKeyID)
+	if err != nil {
+		glog.Errorln("SetBackupPolicy error", err, "requuid", requuid, svc.ServiceUUID)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	glog.Infoln("set backup policy", req.Schedule, req.S3Bucket, req.S3Prefix, "for service", svc.ServiceUUID, "requuid", requuid)
+	return nil
+}
+
+// getBackupStatus returns the last-run outcome and next scheduled run for
+// a service's backup policy.
+func (s *ManageHTTPServer) getBackupStatus(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
+	req := &manage.CatalogBackupStatusRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		glog.Errorln("CatalogBackupStatusRequest decode error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	svc, err := s.dbIns.GetService(ctx, s.cluster, req.Service.ServiceName)
+	if err != nil {
+		glog.Errorln("GetService error", err, "requuid", requuid, req.Service)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	policy, err := s.dbIns.GetBackupPolicy(ctx, svc.ServiceUUID)
+	if err != nil {
+		glog.Errorln("GetBackupPolicy error", err, "requuid", requuid, svc.ServiceUUID)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	resp := &manage.CatalogBackupStatusResponse{
+		Schedule:    policy.Schedule,
+		LastRunTime: policy.LastRunTime,
+		LastSuccess: policy.LastSuccess,
+		LastError:   policy.LastError,
+		NextRunTime: policy.NextRunTime,
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		glog.Errorln("Marshal CatalogBackupStatusResponse error", err, "requuid", requuid)
+		return merrors.Internal(err.Error())
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+	return nil
+}
+
+// StartBackupScheduler polls the backup policies on a fixed interval and
+// runs any schedule whose NextRunTime has passed, until ctx is canceled.
+func (s *ManageHTTPServer) StartBackupScheduler(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runDueBackups(ctx)
+		}
+	}
+}
+
+func (s *ManageHTTPServer) runDueBackups(ctx context.Context) {
+	policies, err := s.dbIns.ListDueBackupPolicies(ctx, time.Now().UnixNano())
+	if err != nil {
+		glog.Errorln("ListDueBackupPolicies error", err)
+		return
+	}
+
+	for _, policy := range policies {
+		if err := s.runBackup(ctx, policy); err != nil {
+			glog.Errorln("runBackup error", err, policy.ServiceUUID)
+		}
+	}
+}
+
+// runBackup dispatches the registered snapshot command for policy's
+// service kind to every member, through the same RunTask path other
+// background task types use. No catalog package registers a
+// backupSnapshotCmd in this build yet, so every call currently fails with
+// "no backup snapshot command registered"; this commit is the policy and
+// scheduling bookkeeping (setBackupPolicy, StartBackupScheduler,
+// runDueBackups, recordBackupResult) plus the dispatch path a real
+// registration plugs into, not a working snapshot for any service yet.
+func (s *ManageHTTPServer) runBackup(ctx context.Context, policy *common.BackupPolicy) error {
+	attr, err := s.dbIns.GetServiceAttr(ctx, policy.ServiceUUID)
+	if err != nil {
+		return s.recordBackupResult(ctx, policy.ServiceUUID, err)
+	}
+
+	buildCmd, ok := backupSnapshotCmds[attr.ServiceType]
+	if !ok {
+		return s.recordBackupResult(ctx, policy.ServiceUUID, fmt.Errorf("no backup snapshot command registered for kind %s", attr.ServiceType))
+	}
+
+	members, err := s.dbIns.ListServiceMembers(ctx, policy.ServiceUUID)
+	if err != nil {
+		return s.recordBackupResult(ctx, policy.ServiceUUID, err)
+	}
+
+	for _, member := range members {
+		// buildCmd's cmd return is not forwarded here: RunTaskOptions has no
+		// field to carry an argv override (see CommonOptions/RunTaskOptions
+		// usages elsewhere in this package), so the container image
+		// entrypoint for TaskTypeBackup is expected to read everything it
+		// needs, including the snapshot command itself, from envkvs.
+		_, envkvs := buildCmd(member, policy.S3Bucket, policy.S3Prefix)
+
+		opts := &containersvc.RunTaskOptions{
+			Common: &containersvc.CommonOptions{
+				Cluster:     s.cluster,
+				ServiceName: attr.ServiceName,
+				ServiceUUID: policy.ServiceUUID,
+			},
+			TaskType: common.TaskTypeBackup,
+			Envkvs:   envkvs,
+		}
+
+		if _, err := s.containersvcIns.RunTask(ctx, opts); err != nil {
+			return s.recordBackupResult(ctx, policy.ServiceUUID, err)
+		}
+	}
+
+	return s.recordBackupResult(ctx, policy.ServiceUUID, nil)
+}
+
+func (s *ManageHTTPServer) recordBackupResult(ctx context.Context, serviceUUID string, runErr error) error {
+	errmsg := ""
+	if runErr != nil {
+		errmsg = runErr.Error()
+	}
+	if err := s.dbIns.UpdateBackupPolicyResult(ctx, serviceUUID, time.Now().UnixNano(), runErr == nil, errmsg); err != nil {
+		glog.Errorln("UpdateBackupPolicyResult error", err, serviceUUID)
+	}
+	return runErr
+}
+
+// restoreService is not fully implemented yet: provisioning the new
+// service needs the original catalog-specific create options (e.g.
+// *rediscatalog.CatalogCreateRedisOptions) to drive
+// CatalogServiceProvider.GenCreateRequest the same way createStackNode
+// does, and CatalogRestoreRequest does not carry them - ServiceAttr only
+// has the common fields (replicas, image, port), not the catalog-specific
+// ones. Until CatalogRestoreRequest gains an Options field (or the backup
+// policy starts recording the original options alongside the schedule),
+// this validates the request and reports what it cannot do instead of
+// reporting success for a restore it never performed.
+func (s *ManageHTTPServer) restoreService(ctx context.Context, r *http.Request, requuid string) merrors.Error {
+	req := &manage.CatalogRestoreRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		glog.Errorln("CatalogRestoreRequest decode error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	manifests, err := s.dbIns.ListBackupManifests(ctx, req.BackupServiceUUID, req.SnapshotID)
+	if err != nil {
+		glog.Errorln("ListBackupManifests error", err, "requuid", requuid, req.BackupServiceUUID)
+		return manage.ConvertToHTTPError(err)
+	}
+	if len(manifests) == 0 {
+		return merrors.BadRequest(fmt.Sprintf("no backup manifest found for service %s snapshot %s", req.BackupServiceUUID, req.SnapshotID))
+	}
+
+	attr, err := s.dbIns.GetServiceAttr(ctx, req.BackupServiceUUID)
+	if err != nil {
+		glog.Errorln("GetServiceAttr error", err, "requuid", requuid, req.BackupServiceUUID)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	if provider := catalog.Get(attr.ServiceType); provider == nil {
+		return merrors.BadRequest(fmt.Sprintf("no CatalogServiceProvider registered for kind %s", attr.ServiceType))
+	}
+
+	glog.Errorln("restoreService not implemented: CatalogRestoreRequest carries no catalog-specific"+
+		" create options to recreate the service, requuid", requuid, "backup service", req.BackupServiceUUID,
+		"as", req.Service.ServiceName, "manifests", len(manifests))
+	return merrors.New("NotImplemented", http.StatusNotImplemented, "restore is not implemented yet")
+}
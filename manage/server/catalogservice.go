@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/golang/glog"
+	"go.uber.org/zap"
 	"golang.org/x/net/context"
 
 	"github.com/cloudstax/firecamp/catalog"
@@ -23,11 +24,53 @@ import (
 	"github.com/cloudstax/firecamp/db"
 	"github.com/cloudstax/firecamp/dns"
 	"github.com/cloudstax/firecamp/manage"
+	merrors "github.com/cloudstax/firecamp/manage/errors"
+	"github.com/cloudstax/firecamp/pkg/log"
 	"github.com/cloudstax/firecamp/utils"
 )
 
+// opCatalogKind maps a special-op URL to the catalog service kind it
+// creates. putCatalogServiceOp consults this to dispatch through the
+// CatalogServiceProvider registry before falling back to the per-service
+// methods below. None of the catalog/mongodb, catalog/redis, etc.
+// packages call catalog.Register from their init() yet - doing so needs
+// the same manage/common request types and ManageHTTPServer fields
+// (s.platform, s.azs, s.manageurl) the switch below already depends on,
+// none of which are declared in this tree - so catalog.Get always
+// returns nil here, the k8s operator controller never finds a provider
+// for any CRD kind either, and CatalogCreateStackOp can resolve a stack's
+// dependency order but cannot create a single real node. The registry
+// and CatalogServiceProvider interface are scaffolding for that future
+// per-kind migration, not a working alternate path today; the switch
+// below remains the only route that creates anything.
+var opCatalogKind = map[string]string{
+	manage.CatalogCreateMongoDBOp:       catalog.CatalogService_MongoDB,
+	manage.CatalogCreatePostgreSQLOp:    catalog.CatalogService_PostgreSQL,
+	manage.CatalogCreateCassandraOp:     catalog.CatalogService_Cassandra,
+	manage.CatalogCreateZooKeeperOp:     catalog.CatalogService_ZooKeeper,
+	manage.CatalogCreateKafkaOp:         catalog.CatalogService_Kafka,
+	manage.CatalogCreateRedisOp:         catalog.CatalogService_Redis,
+	manage.CatalogCreateCouchDBOp:       catalog.CatalogService_CouchDB,
+	manage.CatalogCreateElasticSearchOp: catalog.CatalogService_ElasticSearch,
+	manage.CatalogCreateKibanaOp:        catalog.CatalogService_Kibana,
+	manage.CatalogCreateLogstashOp:      catalog.CatalogService_Logstash,
+}
+
 func (s *ManageHTTPServer) putCatalogServiceOp(ctx context.Context, w http.ResponseWriter,
-	r *http.Request, trimURL string, requuid string) (errmsg string, errcode int) {
+	r *http.Request, trimURL string, requuid string) merrors.Error {
+	// inject a request-scoped structured logger carrying requuid/cluster/region.
+	// Only lines logged through log.FromContext(ctx) carry these fields as
+	// JSON; the glog calls in the catalog handlers still log ad-hoc
+	// positional key/value pairs.
+	ctx = log.NewContext(ctx, log.WithRequestFields(s.logger, requuid, s.cluster, s.region))
+	log.FromContext(ctx).Info("putCatalogServiceOp", zap.String("op", trimURL))
+
+	if kind, ok := opCatalogKind[trimURL]; ok {
+		if provider := catalog.Get(kind); provider != nil {
+			return s.createRegisteredCatalogService(ctx, provider, r, requuid)
+		}
+	}
+
 	switch trimURL {
 	case manage.CatalogCreateMongoDBOp:
 		return s.createMongoDBService(ctx, r, requuid)
@@ -55,27 +98,43 @@ func (s *ManageHTTPServer) putCatalogServiceOp(ctx context.Context, w http.Respo
 		return s.catalogSetServiceInit(ctx, r, requuid)
 	case manage.CatalogSetRedisInitOp:
 		return s.setRedisInit(ctx, r, requuid)
+	case manage.CatalogCreateStackOp:
+		return s.createStackService(ctx, w, r, requuid)
+	case manage.CatalogGCConfigFilesOp:
+		return s.gcConfigFilesOneShot(ctx, r, requuid)
+	case manage.CatalogSetBackupPolicyOp:
+		return s.setBackupPolicy(ctx, r, requuid)
+	case manage.CatalogRestoreOp:
+		return s.restoreService(ctx, r, requuid)
 	default:
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("unknown op " + trimURL)
 	}
 }
 
 func (s *ManageHTTPServer) getCatalogServiceOp(ctx context.Context,
-	w http.ResponseWriter, r *http.Request, requuid string) (errmsg string, errcode int) {
+	w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
+	// inject a request-scoped structured logger carrying requuid/cluster/region.
+	// Only lines logged through log.FromContext(ctx) carry these fields as
+	// JSON; the glog calls below still log ad-hoc positional key/value pairs.
+	ctx = log.NewContext(ctx, log.WithRequestFields(s.logger, requuid, s.cluster, s.region))
+
 	// parse the request
 	req := &manage.CatalogCheckServiceInitRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("CatalogCheckServiceInitRequest decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
 		glog.Errorln("CatalogCheckServiceInitRequest invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
+	reqLogger := log.WithService(log.FromContext(ctx), req.Service.ServiceName, req.ServiceType, "")
+	ctx = log.NewContext(ctx, reqLogger)
+
 	// get service uuid
 	service, err := s.dbIns.GetService(ctx, s.cluster, req.Service.ServiceName)
 	if err != nil {
@@ -83,15 +142,17 @@ func (s *ManageHTTPServer) getCatalogServiceOp(ctx context.Context,
 		return manage.ConvertToHTTPError(err)
 	}
 
+	reqLogger = log.WithService(log.FromContext(ctx), req.Service.ServiceName, req.ServiceType, service.ServiceUUID)
+	ctx = log.NewContext(ctx, reqLogger)
+
 	// check if the init task is running
 	initialized := false
 	hasTask, statusMsg := s.catalogSvcInit.hasInitTask(ctx, service.ServiceUUID)
 	if hasTask {
-		glog.Infoln("The service", req.Service.ServiceName, req.ServiceType,
-			"is under initialization, requuid", requuid)
+		reqLogger.Info("service is under initialization")
 	} else {
 		// no init task is running, check if the service is initialized
-		glog.Infoln("No init task for service", req.Service.ServiceName, req.ServiceType, "requuid", requuid)
+		reqLogger.Info("no init task for service")
 
 		attr, err := s.dbIns.GetServiceAttr(ctx, service.ServiceUUID)
 		if err != nil {
@@ -119,9 +180,8 @@ func (s *ManageHTTPServer) getCatalogServiceOp(ctx context.Context,
 
 			case catalog.CatalogService_PostgreSQL:
 				// PG does not require additional init work. set PG initialized
-				errmsg, errcode := s.setServiceInitialized(ctx, req.Service.ServiceName, requuid)
-				if errcode != http.StatusOK {
-					return errmsg, errcode
+				if err := s.setServiceInitialized(ctx, req.Service.ServiceName, requuid); err != nil {
+					return err
 				}
 				initialized = true
 
@@ -130,17 +190,15 @@ func (s *ManageHTTPServer) getCatalogServiceOp(ctx context.Context,
 
 			case catalog.CatalogService_ZooKeeper:
 				// zookeeper does not require additional init work. set initialized
-				errmsg, errcode := s.setServiceInitialized(ctx, req.Service.ServiceName, requuid)
-				if errcode != http.StatusOK {
-					return errmsg, errcode
+				if err := s.setServiceInitialized(ctx, req.Service.ServiceName, requuid); err != nil {
+					return err
 				}
 				initialized = true
 
 			case catalog.CatalogService_Kafka:
 				// Kafka does not require additional init work. set initialized
-				errmsg, errcode := s.setServiceInitialized(ctx, req.Service.ServiceName, requuid)
-				if errcode != http.StatusOK {
-					return errmsg, errcode
+				if err := s.setServiceInitialized(ctx, req.Service.ServiceName, requuid); err != nil {
+					return err
 				}
 				initialized = true
 
@@ -155,12 +213,12 @@ func (s *ManageHTTPServer) getCatalogServiceOp(ctx context.Context,
 				s.addCouchDBInitTask(ctx, req.Service, attr.ServiceUUID, attr.Replicas, req.Admin, req.AdminPasswd, requuid)
 
 			default:
-				return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+				return merrors.BadRequest("unknown service type " + req.ServiceType)
 			}
 
 		default:
 			glog.Errorln("service is not at active or creating status", attr, "requuid", requuid)
-			return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+			return merrors.BadRequest("service is not at active or creating status")
 		}
 	}
 
@@ -172,34 +230,134 @@ func (s *ManageHTTPServer) getCatalogServiceOp(ctx context.Context,
 	b, err := json.Marshal(resp)
 	if err != nil {
 		glog.Errorln("Marshal error", err, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError
+		return merrors.Internal(err.Error())
 	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(b)
 
-	return "", http.StatusOK
+	return nil
+}
+
+// createRegisteredCatalogService creates and, if needed, initializes a
+// service through a provider registered in the catalog.Registry. Once a
+// catalog/<kind> package registers a CatalogServiceProvider (see
+// opCatalogKind above), this is the decode/validate/
+// GenDefaultCreateServiceRequest/createCommonService/addInitTask path its
+// requests take instead of a dedicated createXxxService method; no
+// package registers one yet, so this is currently unreachable.
+func (s *ManageHTTPServer) createRegisteredCatalogService(ctx context.Context,
+	provider catalog.CatalogServiceProvider, r *http.Request, requuid string) merrors.Error {
+	req, err := provider.Decode(r)
+	if err != nil {
+		glog.Errorln("decode request error", err, "requuid", requuid, "kind", provider.Kind())
+		return merrors.BadRequest(err.Error())
+	}
+
+	err = provider.ValidateRequest(req)
+	if err != nil {
+		glog.Errorln("invalid request", err, "requuid", requuid, "kind", provider.Kind())
+		return merrors.Validation(err.Error())
+	}
+
+	crReq, err := provider.GenCreateRequest(s, req)
+	if err != nil {
+		glog.Errorln("GenCreateRequest error", err, "requuid", requuid, "kind", provider.Kind())
+		return manage.ConvertToHTTPError(err)
+	}
+
+	serviceUUID, err := s.CreateCommonService(ctx, crReq, requuid)
+	if err != nil {
+		glog.Errorln("createCommonService error", err, "requuid", requuid, "kind", provider.Kind())
+		return manage.ConvertToHTTPError(err)
+	}
+
+	if !provider.RequiresInit(req) {
+		glog.Infoln("created service", serviceUUID, "kind", provider.Kind(), "requuid", requuid)
+		return s.setServiceInitialized(ctx, serviceNameOf(crReq), requuid)
+	}
+
+	taskOpts, err := provider.GenInitTask(s, req, crReq, serviceUUID, requuid)
+	if err != nil {
+		glog.Errorln("GenInitTask error", err, "requuid", requuid, "kind", provider.Kind(), serviceUUID)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	s.ScheduleInitTask(ctx, provider.Kind(), serviceUUID, serviceNameOf(crReq), taskOpts)
+
+	glog.Infoln("created service", serviceUUID, "kind", provider.Kind(), "added init task, requuid", requuid)
+	return nil
+}
+
+// CatalogServiceEnv is catalog.ServiceEnv, re-exported so callers outside
+// this package (e.g. the k8s operator controller) can depend on the
+// interface without importing the catalog package directly.
+type CatalogServiceEnv = catalog.ServiceEnv
+
+// CreateCommonService, ScheduleInitTask and SetServiceInitialized implement
+// catalog.ServiceEnv, so registered providers can drive service creation
+// and initialization without importing manageserver.
+
+// CreateCommonService implements catalog.ServiceEnv.
+func (s *ManageHTTPServer) CreateCommonService(ctx context.Context, crReq interface{}, requuid string) (string, error) {
+	req, ok := crReq.(*manage.CreateServiceRequest)
+	if !ok {
+		return "", common.ErrInternal
+	}
+
+	serviceUUID, err := s.createCommonService(ctx, req, requuid)
+	if err != nil {
+		return "", err
+	}
+
+	s.registerInConsul(ctx, serviceUUID, requuid)
+
+	return serviceUUID, nil
 }
 
-func (s *ManageHTTPServer) createMongoDBService(ctx context.Context, r *http.Request, requuid string) (errmsg string, errcode int) {
+// ScheduleInitTask implements catalog.ServiceEnv. It goes through
+// persistAndAddInitTask, the same as every createXxxService method below,
+// so a provider-driven init task is journaled and survives a manage
+// server restart rather than only living in the in-memory task runner.
+func (s *ManageHTTPServer) ScheduleInitTask(ctx context.Context, kind string, serviceUUID string, serviceName string, taskOpts interface{}) {
+	s.persistAndAddInitTask(ctx, kind, serviceUUID, serviceName, taskOpts, "")
+}
+
+// SetServiceInitialized implements catalog.ServiceEnv.
+func (s *ManageHTTPServer) SetServiceInitialized(ctx context.Context, serviceName string, requuid string) error {
+	if err := s.setServiceInitialized(ctx, serviceName, requuid); err != nil {
+		return err
+	}
+	return nil
+}
+
+func serviceNameOf(crReq interface{}) string {
+	req, ok := crReq.(*manage.CreateServiceRequest)
+	if !ok {
+		return ""
+	}
+	return req.Service.ServiceName
+}
+
+func (s *ManageHTTPServer) createMongoDBService(ctx context.Context, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.CatalogCreateMongoDBRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("CatalogCreateMongoDBRequest decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
 		glog.Errorln("CatalogCreateMongoDBRequest invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	err = mongodbcatalog.ValidateRequest(req)
 	if err != nil {
 		glog.Errorln("invalid request", err, "requuid", requuid, req.Service, req.Options)
-		return err.Error(), http.StatusBadRequest
+		return merrors.Validation(err.Error())
 	}
 
 	// create the service in the control plane and the container platform
@@ -221,7 +379,7 @@ func (s *ManageHTTPServer) createMongoDBService(ctx context.Context, r *http.Req
 	// run the init task in the background
 	s.addMongoDBInitTask(ctx, crReq.Service, serviceUUID, req.Options.Replicas, req.Options.Admin, req.Options.AdminPasswd, requuid)
 
-	return "", http.StatusOK
+	return nil
 }
 
 func (s *ManageHTTPServer) addMongoDBInitTask(ctx context.Context, req *manage.ServiceCommonRequest,
@@ -229,37 +387,30 @@ func (s *ManageHTTPServer) addMongoDBInitTask(ctx context.Context, req *manage.S
 	logCfg := s.logIns.CreateLogConfigForStream(ctx, s.cluster, req.ServiceName, serviceUUID, common.TaskTypeInit)
 	taskOpts := mongodbcatalog.GenDefaultInitTaskRequest(req, logCfg, serviceUUID, replicas, s.manageurl, admin, adminPasswd)
 
-	task := &serviceTask{
-		serviceUUID: serviceUUID,
-		serviceName: req.ServiceName,
-		serviceType: catalog.CatalogService_MongoDB,
-		opts:        taskOpts,
-	}
-
-	s.catalogSvcInit.addInitTask(ctx, task)
+	s.persistAndAddInitTask(ctx, catalog.CatalogService_MongoDB, serviceUUID, req.ServiceName, taskOpts, requuid)
 
 	glog.Infoln("add init task for service", serviceUUID, "requuid", requuid, req)
 }
 
-func (s *ManageHTTPServer) createPGService(ctx context.Context, r *http.Request, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) createPGService(ctx context.Context, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.CatalogCreatePostgreSQLRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("CatalogCreatePostgreSQLRequest decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
 		glog.Errorln("CatalogCreatePostgreSQLRequest invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	err = pgcatalog.ValidateRequest(req)
 	if err != nil {
 		glog.Errorln("invalid request", err, "requuid", requuid, req.Service, req.Options)
-		return err.Error(), http.StatusBadRequest
+		return merrors.Validation(err.Error())
 	}
 
 	// create the service in the control plane and the container platform
@@ -276,19 +427,19 @@ func (s *ManageHTTPServer) createPGService(ctx context.Context, r *http.Request,
 	return s.setServiceInitialized(ctx, req.Service.ServiceName, requuid)
 }
 
-func (s *ManageHTTPServer) createZkService(ctx context.Context, r *http.Request, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) createZkService(ctx context.Context, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.CatalogCreateZooKeeperRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("CatalogCreateZooKeeperRequest decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
 		glog.Errorln("CatalogCreateZooKeeperRequest invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	// create the service in the control plane and the container platform
@@ -306,19 +457,19 @@ func (s *ManageHTTPServer) createZkService(ctx context.Context, r *http.Request,
 	return s.setServiceInitialized(ctx, req.Service.ServiceName, requuid)
 }
 
-func (s *ManageHTTPServer) createKafkaService(ctx context.Context, r *http.Request, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) createKafkaService(ctx context.Context, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.CatalogCreateKafkaRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("CatalogCreateKafkaRequest decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
 		glog.Errorln("CatalogCreateKafkaRequest invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	// get the zk service
@@ -351,19 +502,19 @@ func (s *ManageHTTPServer) createKafkaService(ctx context.Context, r *http.Reque
 	return s.setServiceInitialized(ctx, req.Service.ServiceName, requuid)
 }
 
-func (s *ManageHTTPServer) createRedisService(ctx context.Context, r *http.Request, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) createRedisService(ctx context.Context, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.CatalogCreateRedisRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("CatalogCreateRedisRequest decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
 		glog.Errorln("CatalogCreateRedisRequest invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	glog.Infoln("create redis service", req.Service, req.Options, req.Resource)
@@ -371,7 +522,7 @@ func (s *ManageHTTPServer) createRedisService(ctx context.Context, r *http.Reque
 	err = rediscatalog.ValidateRequest(req)
 	if err != nil {
 		glog.Errorln("CatalogCreateRedisRequest parameters are not valid, requuid", requuid, req.Service, req.Options)
-		return err.Error(), http.StatusBadRequest
+		return merrors.Validation(err.Error())
 	}
 
 	// create the service in the control plane and the container platform
@@ -393,7 +544,7 @@ func (s *ManageHTTPServer) createRedisService(ctx context.Context, r *http.Reque
 			return manage.ConvertToHTTPError(err)
 		}
 
-		return "", http.StatusOK
+		return nil
 	}
 
 	// redis single instance or master-slave mode does not require additional init work. set service initialized
@@ -411,38 +562,31 @@ func (s *ManageHTTPServer) addRedisInitTask(ctx context.Context, req *manage.Ser
 		return err
 	}
 
-	task := &serviceTask{
-		serviceUUID: serviceUUID,
-		serviceName: req.ServiceName,
-		serviceType: catalog.CatalogService_Redis,
-		opts:        taskOpts,
-	}
-
-	s.catalogSvcInit.addInitTask(ctx, task)
+	s.persistAndAddInitTask(ctx, catalog.CatalogService_Redis, serviceUUID, req.ServiceName, taskOpts, requuid)
 
 	glog.Infoln("add init task for Redis service", serviceUUID, "requuid", requuid, req)
 	return nil
 }
 
-func (s *ManageHTTPServer) createCouchDBService(ctx context.Context, r *http.Request, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) createCouchDBService(ctx context.Context, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.CatalogCreateCouchDBRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("CatalogCreateCouchDBRequest decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
 		glog.Errorln("CatalogCreateCouchDBRequest invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	err = couchdbcatalog.ValidateRequest(req)
 	if err != nil {
 		glog.Errorln("CatalogCreateCouchDBRequest parameters are not valid, requuid", requuid, req)
-		return err.Error(), http.StatusBadRequest
+		return merrors.Validation(err.Error())
 	}
 
 	// create the service in the control plane and the container platform
@@ -459,7 +603,7 @@ func (s *ManageHTTPServer) createCouchDBService(ctx context.Context, r *http.Req
 
 	glog.Infoln("created CouchDB service", serviceUUID, "requuid", requuid, req.Service, req.Options)
 
-	return "", http.StatusOK
+	return nil
 }
 
 func (s *ManageHTTPServer) addCouchDBInitTask(ctx context.Context, req *manage.ServiceCommonRequest,
@@ -467,37 +611,30 @@ func (s *ManageHTTPServer) addCouchDBInitTask(ctx context.Context, req *manage.S
 	logCfg := s.logIns.CreateLogConfigForStream(ctx, s.cluster, req.ServiceName, serviceUUID, common.TaskTypeInit)
 	taskOpts := couchdbcatalog.GenDefaultInitTaskRequest(req, logCfg, s.azs, serviceUUID, replicas, s.manageurl, admin, adminPass)
 
-	task := &serviceTask{
-		serviceUUID: serviceUUID,
-		serviceName: req.ServiceName,
-		serviceType: catalog.CatalogService_CouchDB,
-		opts:        taskOpts,
-	}
-
-	s.catalogSvcInit.addInitTask(ctx, task)
+	s.persistAndAddInitTask(ctx, catalog.CatalogService_CouchDB, serviceUUID, req.ServiceName, taskOpts, requuid)
 
 	glog.Infoln("add init task for CouchDB service", serviceUUID, "requuid", requuid, req)
 }
 
-func (s *ManageHTTPServer) createConsulService(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) createConsulService(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.CatalogCreateConsulRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("CatalogCreateConsulRequest decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
 		glog.Errorln("CatalogCreateConsulRequest invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	err = consulcatalog.ValidateRequest(req)
 	if err != nil {
 		glog.Errorln("CatalogCreateConsulRequest parameters are not valid, requuid", requuid, req)
-		return err.Error(), http.StatusBadRequest
+		return merrors.Validation(err.Error())
 	}
 
 	// create the service in the control plane and the container platform
@@ -522,6 +659,8 @@ func (s *ManageHTTPServer) createConsulService(ctx context.Context, w http.Respo
 		return manage.ConvertToHTTPError(err)
 	}
 
+	s.setConsulRegistrar(serverips, requuid)
+
 	err = s.createContainerService(ctx, crReq, serviceUUID, requuid)
 	if err != nil {
 		glog.Errorln("createContainerService error", err, "requuid", requuid, req.Service)
@@ -531,44 +670,43 @@ func (s *ManageHTTPServer) createConsulService(ctx context.Context, w http.Respo
 	glog.Infoln("created Consul service", serviceUUID, "server ips", serverips, "requuid", requuid, req.Service, req.Options)
 
 	// consul does not require additional init work. set service initialized
-	errmsg, errcode = s.setServiceInitialized(ctx, req.Service.ServiceName, requuid)
-	if len(errmsg) != 0 {
-		glog.Errorln("setServiceInitialized error", errcode, errmsg, "requuid", requuid, req.Service, req.Options)
-		return errmsg, errcode
+	if err := s.setServiceInitialized(ctx, req.Service.ServiceName, requuid); err != nil {
+		glog.Errorln("setServiceInitialized error", err, "requuid", requuid, req.Service, req.Options)
+		return err
 	}
 
 	resp := &manage.CatalogCreateConsulResponse{ConsulServerIPs: serverips}
 	b, err := json.Marshal(resp)
 	if err != nil {
 		glog.Errorln("Marshal CatalogCreateConsulResponse error", err, "requuid", requuid, req.Service, req.Options)
-		return http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError
+		return merrors.Internal(err.Error())
 	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(b)
 
-	return "", http.StatusOK
+	return nil
 }
 
-func (s *ManageHTTPServer) createElasticSearchService(ctx context.Context, r *http.Request, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) createElasticSearchService(ctx context.Context, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.CatalogCreateElasticSearchRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("CatalogCreateElasticSearchRequest decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
 		glog.Errorln("CatalogCreateElasticSearchRequest invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	err = escatalog.ValidateRequest(req)
 	if err != nil {
 		glog.Errorln("invalid elasticsearch create request", err, "requuid", requuid, req)
-		return err.Error(), http.StatusBadRequest
+		return merrors.Validation(err.Error())
 	}
 
 	// create the service in the control plane and the container platform
@@ -586,25 +724,25 @@ func (s *ManageHTTPServer) createElasticSearchService(ctx context.Context, r *ht
 	return s.setServiceInitialized(ctx, req.Service.ServiceName, requuid)
 }
 
-func (s *ManageHTTPServer) createKibanaService(ctx context.Context, r *http.Request, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) createKibanaService(ctx context.Context, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.CatalogCreateKibanaRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("CatalogCreateKibanaRequest decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
 		glog.Errorln("CatalogCreateKibanaRequest invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	err = kibanacatalog.ValidateRequest(req)
 	if err != nil {
 		glog.Errorln("invalid kibana create request", err, "requuid", requuid, req.Options)
-		return err.Error(), http.StatusBadRequest
+		return merrors.Validation(err.Error())
 	}
 
 	// get the dedicated master nodes of the elasticsearch service
@@ -639,25 +777,25 @@ func (s *ManageHTTPServer) createKibanaService(ctx context.Context, r *http.Requ
 	return s.setServiceInitialized(ctx, req.Service.ServiceName, requuid)
 }
 
-func (s *ManageHTTPServer) createLogstashService(ctx context.Context, r *http.Request, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) createLogstashService(ctx context.Context, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.CatalogCreateLogstashRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("CatalogCreateLogstashRequest decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
 		glog.Errorln("CatalogCreateLogstashRequest invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	err = logstashcatalog.ValidateRequest(req)
 	if err != nil {
 		glog.Errorln("invalid logstash create request", err, "requuid", requuid, req.Options)
-		return err.Error(), http.StatusBadRequest
+		return merrors.Validation(err.Error())
 	}
 
 	// create the service in the control plane and the container platform
@@ -675,25 +813,25 @@ func (s *ManageHTTPServer) createLogstashService(ctx context.Context, r *http.Re
 	return s.setServiceInitialized(ctx, req.Service.ServiceName, requuid)
 }
 
-func (s *ManageHTTPServer) createCasService(ctx context.Context, r *http.Request, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) createCasService(ctx context.Context, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.CatalogCreateCassandraRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("CatalogCreateCassandraRequest decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
 		glog.Errorln("CatalogCreateCassandraRequest invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	err = cascatalog.ValidateRequest(req)
 	if err != nil {
 		glog.Errorln("invalid request", err, "requuid", requuid, req.Service, req.Options)
-		return err.Error(), http.StatusBadRequest
+		return merrors.Validation(err.Error())
 	}
 
 	// create the service in the control plane and the container platform
@@ -710,7 +848,7 @@ func (s *ManageHTTPServer) createCasService(ctx context.Context, r *http.Request
 	// run the init task in the background
 	s.addCasInitTask(ctx, crReq.Service, serviceUUID, requuid)
 
-	return "", http.StatusOK
+	return nil
 }
 
 func (s *ManageHTTPServer) addCasInitTask(ctx context.Context,
@@ -718,31 +856,24 @@ func (s *ManageHTTPServer) addCasInitTask(ctx context.Context,
 	logCfg := s.logIns.CreateLogConfigForStream(ctx, s.cluster, req.ServiceName, serviceUUID, common.TaskTypeInit)
 	taskOpts := cascatalog.GenDefaultInitTaskRequest(req, logCfg, serviceUUID, s.manageurl)
 
-	task := &serviceTask{
-		serviceUUID: serviceUUID,
-		serviceName: req.ServiceName,
-		serviceType: catalog.CatalogService_Cassandra,
-		opts:        taskOpts,
-	}
-
-	s.catalogSvcInit.addInitTask(ctx, task)
+	s.persistAndAddInitTask(ctx, catalog.CatalogService_Cassandra, serviceUUID, req.ServiceName, taskOpts, requuid)
 
 	glog.Infoln("add init task for service", serviceUUID, "requuid", requuid, req)
 }
 
-func (s *ManageHTTPServer) catalogSetServiceInit(ctx context.Context, r *http.Request, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) catalogSetServiceInit(ctx context.Context, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.CatalogSetServiceInitRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("CatalogSetServiceInitRequest decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Cluster != s.cluster || req.Region != s.region {
 		glog.Errorln("CatalogSetServiceInitRequest invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	switch req.ServiceType {
@@ -760,11 +891,11 @@ func (s *ManageHTTPServer) catalogSetServiceInit(ctx context.Context, r *http.Re
 	// other services do not require the init task.
 	default:
 		glog.Errorln("unknown service type", req)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("unknown service type " + req.ServiceType)
 	}
 }
 
-func (s *ManageHTTPServer) setMongoDBInit(ctx context.Context, req *manage.CatalogSetServiceInitRequest, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) setMongoDBInit(ctx context.Context, req *manage.CatalogSetServiceInitRequest, requuid string) merrors.Error {
 	// get service uuid
 	service, err := s.dbIns.GetService(ctx, s.cluster, req.ServiceName)
 	if err != nil {
@@ -786,7 +917,7 @@ func (s *ManageHTTPServer) setMongoDBInit(ctx context.Context, req *manage.Catal
 		return manage.ConvertToHTTPError(err)
 	}
 
-	glog.Infoln("get service", service, "has", len(members), "replicas, requuid", requuid)
+	glog.Infoln("get service", service, attr, "has", len(members), "replicas, requuid", requuid)
 
 	// update the init task status message
 	statusMsg := "enable auth for MongoDB"
@@ -813,16 +944,15 @@ func (s *ManageHTTPServer) setMongoDBInit(ctx context.Context, req *manage.Catal
 		}
 	}
 
-	// the config files of all replicas are updated, restart all containers
-	glog.Infoln("all replicas are updated, restart all containers, requuid", requuid, req)
-
-	// update the init task status message
-	statusMsg = "restarting all MongoDB containers"
-	s.catalogSvcInit.UpdateTaskStatusMsg(service.ServiceUUID, statusMsg)
+	// the config files of all replicas are updated, roll the containers one
+	// member at a time instead of restarting the whole replica set, so a
+	// primary-secondary MongoDB stays available to reads during init.
+	glog.Infoln("all replicas are updated, rolling restart the containers, requuid", requuid, req)
 
-	err = s.containersvcIns.RestartService(ctx, s.cluster, req.ServiceName, attr.Replicas)
+	err = s.RollingRestartService(ctx, service.ServiceUUID, req.ServiceName, members,
+		mongodbcatalog.CheckMemberHealth, req.FailurePolicy, req.MaxFailures)
 	if err != nil {
-		glog.Errorln("RestartService error", err, "requuid", requuid, req)
+		glog.Errorln("RollingRestartService error", err, "requuid", requuid, req)
 		return manage.ConvertToHTTPError(err)
 	}
 
@@ -834,38 +964,28 @@ func (s *ManageHTTPServer) setMongoDBInit(ctx context.Context, req *manage.Catal
 
 func (s *ManageHTTPServer) enableMongoDBAuth(ctx context.Context,
 	cfg *common.MemberConfig, cfgIndex int, member *common.ServiceMember, requuid string) error {
-	// fetch the config file
-	cfgfile, err := s.dbIns.GetConfigFile(ctx, member.ServiceUUID, cfg.FileID)
-	if err != nil {
-		glog.Errorln("GetConfigFile error", err, "requuid", requuid, cfg, member)
-		return err
-	}
-
-	// if auth is enabled, return
-	if mongodbcatalog.IsAuthEnabled(cfgfile.Content) {
-		glog.Infoln("auth is already enabled in the config file", db.PrintConfigFile(cfgfile), "requuid", requuid, member)
-		return nil
-	}
-
-	// auth is not enabled, enable it
-	newContent := mongodbcatalog.EnableMongoDBAuth(cfgfile.Content)
-
-	return s.updateMemberConfig(ctx, member, cfgfile, cfgIndex, newContent, requuid)
+	return s.updateMemberConfigWithRetry(ctx, member.ServiceUUID, member.MemberName, cfg.FileName,
+		func(content string) (newContent string, ok bool) {
+			if mongodbcatalog.IsAuthEnabled(content) {
+				return "", false
+			}
+			return mongodbcatalog.EnableMongoDBAuth(content), true
+		}, requuid)
 }
 
-func (s *ManageHTTPServer) setRedisInit(ctx context.Context, r *http.Request, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) setRedisInit(ctx context.Context, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.CatalogSetRedisInitRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("CatalogSetRedisInitRequest decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Cluster != s.cluster || req.Region != s.region {
 		glog.Errorln("CatalogSetRedisInitRequest invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	glog.Infoln("setRedisInit", req.ServiceName, "first node id mapping", req.NodeIds[0], "total", len(req.NodeIds), "requuid", requuid)
@@ -891,7 +1011,7 @@ func (s *ManageHTTPServer) setRedisInit(ctx context.Context, r *http.Request, re
 		return manage.ConvertToHTTPError(err)
 	}
 
-	glog.Infoln("get service", service, "has", len(members), "replicas, requuid", requuid)
+	glog.Infoln("get service", service, attr, "has", len(members), "replicas, requuid", requuid)
 
 	// update the init task status message
 	statusMsg := "create the member to Redis nodeID mapping for the Redis cluster"
@@ -928,16 +1048,15 @@ func (s *ManageHTTPServer) setRedisInit(ctx context.Context, r *http.Request, re
 		}
 	}
 
-	// the config files of all replicas are updated, restart all containers
-	glog.Infoln("all replicas are updated, restart all containers, requuid", requuid, req)
-
-	// update the init task status message
-	statusMsg = "restarting all containers"
-	s.catalogSvcInit.UpdateTaskStatusMsg(service.ServiceUUID, statusMsg)
+	// the config files of all replicas are updated, roll the containers one
+	// member at a time so the Redis cluster keeps serving the shards that
+	// are not currently being restarted.
+	glog.Infoln("all replicas are updated, rolling restart the containers, requuid", requuid, req)
 
-	err = s.containersvcIns.RestartService(ctx, s.cluster, req.ServiceName, attr.Replicas)
+	err = s.RollingRestartService(ctx, service.ServiceUUID, req.ServiceName, members,
+		rediscatalog.CheckMemberHealth, req.FailurePolicy, req.MaxFailures)
 	if err != nil {
-		glog.Errorln("RestartService error", err, "requuid", requuid, req)
+		glog.Errorln("RollingRestartService error", err, "requuid", requuid, req)
 		return manage.ConvertToHTTPError(err)
 	}
 
@@ -947,86 +1066,102 @@ func (s *ManageHTTPServer) setRedisInit(ctx context.Context, r *http.Request, re
 	return s.setServiceInitialized(ctx, req.ServiceName, requuid)
 }
 
+// createRedisClusterFile adds the Redis cluster info file to member if it
+// does not already have one, reusing the same re-fetch-and-retry-on-conflict
+// skeleton as updateMemberConfigWithRetry (see retryOnConflict): a
+// concurrent init flow may have added the file (or anything else) to this
+// member between attempts, so member is re-fetched before each retry.
 func (s *ManageHTTPServer) createRedisClusterFile(ctx context.Context, member *common.ServiceMember,
-	cfg *manage.ReplicaConfigFile, requuid string) (newMember *common.ServiceMember, err error) {
+	cfg *manage.ReplicaConfigFile, requuid string) (*common.ServiceMember, error) {
+	var newMember *common.ServiceMember
 
-	// check if member has the cluster info file, as failure could happen at any time and init task will be retried.
-	for _, c := range member.Configs {
-		if rediscatalog.IsClusterInfoFile(c.FileName) {
-			chksum := utils.GenMD5(cfg.Content)
-			if c.FileMD5 != chksum {
-				// this is an unknown internal error. the cluster info content should be the same between retries.
-				glog.Errorln("Redis cluster file exist but content not match, new content", cfg.Content, chksum,
-					"existing config", c, "requuid", requuid, member)
-				return nil, common.ErrConfigMismatch
+	err := retryOnConflict(requuid, "createRedisClusterFile", func(attempt int) error {
+		if attempt > 0 {
+			m, err := s.dbIns.GetServiceMember(ctx, member.ServiceUUID, member.MemberName)
+			if err != nil {
+				return err
 			}
+			member = m
+		}
 
-			glog.Infoln("Redis cluster file is already created for member", member.MemberName,
-				"service", member.ServiceUUID, "requuid", requuid)
-			return member, nil
+		// check if member has the cluster info file, as failure could happen at any time and init task will be retried.
+		for _, c := range member.Configs {
+			if rediscatalog.IsClusterInfoFile(c.FileName) {
+				chksum := utils.GenMD5(cfg.Content)
+				if c.FileMD5 != chksum {
+					// this is an unknown internal error. the cluster info content should be the same between retries.
+					glog.Errorln("Redis cluster file exist but content not match, new content", cfg.Content, chksum,
+						"existing config", c, "requuid", requuid, member)
+					return common.ErrConfigMismatch
+				}
+
+				glog.Infoln("Redis cluster file is already created for member", member.MemberName,
+					"service", member.ServiceUUID, "requuid", requuid)
+				newMember = member
+				return nil
+			}
 		}
-	}
 
-	// the cluster info file not exist, create it
-	version := int64(0)
-	fileID := utils.GenMemberConfigFileID(member.MemberName, cfg.FileName, version)
-	initcfgfile := db.CreateInitialConfigFile(member.ServiceUUID, fileID, cfg.FileName, cfg.FileMode, cfg.Content)
-	cfgfile, err := manage.CreateConfigFile(ctx, s.dbIns, initcfgfile, requuid)
-	if err != nil {
-		glog.Errorln("createConfigFile error", err, "fileID", fileID,
-			"service", member.ServiceUUID, "member", member.MemberName, "requuid", requuid)
-		return nil, err
-	}
+		// the cluster info file not exist, create it
+		version := int64(0)
+		fileID := utils.GenMemberConfigFileID(member.MemberName, cfg.FileName, version)
+		initcfgfile := db.CreateInitialConfigFile(member.ServiceUUID, fileID, cfg.FileName, cfg.FileMode, cfg.Content)
+		cfgfile, err := manage.CreateConfigFile(ctx, s.dbIns, initcfgfile, requuid)
+		if err != nil {
+			glog.Errorln("createConfigFile error", err, "fileID", fileID,
+				"service", member.ServiceUUID, "member", member.MemberName, "requuid", requuid)
+			return err
+		}
 
-	glog.Infoln("created the Redis cluster config file, requuid", requuid, db.PrintConfigFile(cfgfile))
+		glog.Infoln("created the Redis cluster config file, requuid", requuid, db.PrintConfigFile(cfgfile))
 
-	// add the new config file to ServiceMember
-	config := &common.MemberConfig{FileName: cfg.FileName, FileID: fileID, FileMD5: cfgfile.FileMD5}
+		// add the new config file to ServiceMember
+		config := &common.MemberConfig{FileName: cfg.FileName, FileID: fileID, FileMD5: cfgfile.FileMD5}
 
-	newConfigs := db.CopyMemberConfigs(member.Configs)
-	newConfigs = append(newConfigs, config)
+		newConfigs := db.CopyMemberConfigs(member.Configs)
+		newConfigs = append(newConfigs, config)
+
+		updated := db.UpdateServiceMemberConfigs(member, newConfigs)
+		if err := s.dbIns.UpdateServiceMember(ctx, member, updated); err != nil {
+			glog.Errorln("UpdateServiceMember error", err, "requuid", requuid, member)
+			// the config file created above was never referenced; delete it so
+			// this conflict (or any other failure) does not leave it as garbage.
+			if delErr := s.dbIns.DeleteConfigFile(ctx, cfgfile.ServiceUUID, cfgfile.FileID); delErr != nil {
+				glog.Errorln("DeleteConfigFile error for orphaned cluster config file", delErr, "requuid", requuid, db.PrintConfigFile(cfgfile))
+			}
+			return err
+		}
+
+		glog.Infoln("added the cluster config to service member", member.MemberName, member.ServiceUUID, "requuid", requuid)
+		newMember = updated
+		return nil
+	})
 
-	newMember = db.UpdateServiceMemberConfigs(member, newConfigs)
-	err = s.dbIns.UpdateServiceMember(ctx, member, newMember)
 	if err != nil {
-		glog.Errorln("UpdateServiceMember error", err, "requuid", requuid, member)
 		return nil, err
 	}
-
-	glog.Infoln("added the cluster config to service member", member.MemberName, member.ServiceUUID, "requuid", requuid)
 	return newMember, nil
 }
 
-// TODO most code is the same with enableMongoDBAuth, unify it to avoid duplicate code.
 func (s *ManageHTTPServer) updateRedisConfigs(ctx context.Context,
 	cfg *common.MemberConfig, cfgIndex int, member *common.ServiceMember, requuid string) error {
-	// fetch the config file
-	cfgfile, err := s.dbIns.GetConfigFile(ctx, member.ServiceUUID, cfg.FileID)
-	if err != nil {
-		glog.Errorln("GetConfigFile error", err, "requuid", requuid, cfg, member)
-		return err
-	}
-
-	// if auth is enabled, return
-	enableAuth := rediscatalog.NeedToEnableAuth(cfgfile.Content)
-	setIP := rediscatalog.NeedToSetClusterAnnounceIP(cfgfile.Content)
-
-	if !enableAuth && !setIP {
-		glog.Infoln("auth and cluster-announce-ip are already set in the config file", db.PrintConfigFile(cfgfile), "requuid", requuid, member)
-		return nil
-	}
-
-	newContent := cfgfile.Content
-	if enableAuth {
-		// auth is not enabled, enable it
-		newContent = rediscatalog.EnableRedisAuth(newContent)
-	}
-	if setIP {
-		// cluster-announce-ip not set, set it
-		newContent = rediscatalog.SetClusterAnnounceIP(newContent, member.StaticIP)
-	}
+	return s.updateMemberConfigWithRetry(ctx, member.ServiceUUID, member.MemberName, cfg.FileName,
+		func(content string) (newContent string, ok bool) {
+			enableAuth := rediscatalog.NeedToEnableAuth(content)
+			setIP := rediscatalog.NeedToSetClusterAnnounceIP(content)
+			if !enableAuth && !setIP {
+				return "", false
+			}
 
-	return s.updateMemberConfig(ctx, member, cfgfile, cfgIndex, newContent, requuid)
+			newContent = content
+			if enableAuth {
+				newContent = rediscatalog.EnableRedisAuth(newContent)
+			}
+			if setIP {
+				newContent = rediscatalog.SetClusterAnnounceIP(newContent, member.StaticIP)
+			}
+			return newContent, true
+		}, requuid)
 }
 
 func (s *ManageHTTPServer) updateConsulConfigs(ctx context.Context, serviceUUID string, domain string, requuid string) (serverips []string, err error) {
@@ -1056,29 +1191,25 @@ func (s *ManageHTTPServer) updateConsulConfigs(ctx context.Context, serviceUUID
 	return serverips, nil
 }
 
-// TODO most code is the same with enableMongoDBAuth, unify it to avoid duplicate code.
 func (s *ManageHTTPServer) updateConsulMemberConfig(ctx context.Context, member *common.ServiceMember, memberips map[string]string, requuid string) error {
 	var cfg *common.MemberConfig
-	cfgIndex := -1
-	for i, c := range member.Configs {
+	for _, c := range member.Configs {
 		if consulcatalog.IsBasicConfigFile(c.FileName) {
 			cfg = c
-			cfgIndex = i
 			break
 		}
 	}
-
-	// fetch the config file
-	cfgfile, err := s.dbIns.GetConfigFile(ctx, member.ServiceUUID, cfg.FileID)
-	if err != nil {
-		glog.Errorln("GetConfigFile error", err, "requuid", requuid, cfg, member)
-		return err
+	if cfg == nil {
+		glog.Errorln("member has no basic_config.json file", "requuid", requuid, member)
+		return common.ErrConfigMismatch
 	}
 
-	// replace the original member dns name by member ip
-	newContent := consulcatalog.ReplaceMemberName(cfgfile.Content, memberips)
-
-	return s.updateMemberConfig(ctx, member, cfgfile, cfgIndex, newContent, requuid)
+	return s.updateMemberConfigWithRetry(ctx, member.ServiceUUID, member.MemberName, cfg.FileName,
+		func(content string) (newContent string, ok bool) {
+			// replace the original member dns name by member ip
+			newContent = consulcatalog.ReplaceMemberName(content, memberips)
+			return newContent, newContent != content
+		}, requuid)
 }
 
 func (s *ManageHTTPServer) updateMemberConfig(ctx context.Context, member *common.ServiceMember,
@@ -1110,15 +1241,20 @@ func (s *ManageHTTPServer) updateMemberConfig(ctx context.Context, member *commo
 	err = s.dbIns.UpdateServiceMember(ctx, member, newMember)
 	if err != nil {
 		glog.Errorln("UpdateServiceMember error", err, "requuid", requuid, member)
+		// the new config file above was already created but never referenced.
+		// delete it so a conflict (or any other failure) here does not leak it;
+		// the caller may still retry with a freshly re-fetched member.
+		if delErr := s.dbIns.DeleteConfigFile(ctx, newcfgfile.ServiceUUID, newcfgfile.FileID); delErr != nil {
+			glog.Errorln("DeleteConfigFile error for orphaned config file", delErr, "requuid", requuid, db.PrintConfigFile(newcfgfile))
+		}
 		return err
 	}
 
 	glog.Infoln("updated member configs in the serviceMember, requuid", requuid, newMember)
 
-	// delete the old config file.
-	// TODO add the background gc mechanism to delete the garbage.
-	//      the old config file may not be deleted at some conditions.
-	//			for example, node crashes right before deleting the config file.
+	// delete the old config file. If this fails (e.g. a crash right
+	// before the delete), the file is left referenced by nothing and
+	// StartConfigFileGC picks it up on its next sweep.
 	err = s.dbIns.DeleteConfigFile(ctx, cfgfile.ServiceUUID, cfgfile.FileID)
 	if err != nil {
 		// simply log an error as this only leaves a garbage
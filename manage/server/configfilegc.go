@@ -0,0 +1,162 @@
+package manageserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudstax/firecamp/manage"
+	merrors "github.com/cloudstax/firecamp/manage/errors"
+)
+
+var (
+	gcConfigFilesDeletedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "firecamp_manageserver_gc_config_files_deleted_total",
+		Help: "Total number of orphaned config files deleted by the config file gc.",
+	})
+	gcConfigFileDeleteErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "firecamp_manageserver_gc_config_file_delete_errors_total",
+		Help: "Total number of errors deleting an orphaned config file.",
+	})
+	gcMissingConfigFilesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "firecamp_manageserver_gc_missing_config_files_total",
+		Help: "Total number of ServiceMember config references found pointing at a missing ConfigFile.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(gcConfigFilesDeletedTotal, gcConfigFileDeleteErrors, gcMissingConfigFilesTotal)
+}
+
+// configFileGCInterval is how often StartConfigFileGC sweeps every
+// service for orphaned config files.
+const configFileGCInterval = 1 * time.Hour
+
+// configFileGCGracePeriod skips any config file created more recently
+// than this, so the gc does not race with an in-flight updateMemberConfig
+// (or enableMongoDBAuth / updateRedisConfigs / updateConsulMemberConfig)
+// call that created the new file but has not yet pointed the
+// ServiceMember at it.
+const configFileGCGracePeriod = 1 * time.Hour
+
+// StartConfigFileGC runs the orphaned-config-file sweep on
+// configFileGCInterval until ctx is canceled. It exists because a crash
+// between UpdateServiceMember and DeleteConfigFile in updateMemberConfig
+// leaves an orphan ConfigFile row in the db with nothing pointing at it.
+func (s *ManageHTTPServer) StartConfigFileGC(ctx context.Context) {
+	ticker := time.NewTicker(configFileGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.gcAllServices(ctx)
+		}
+	}
+}
+
+func (s *ManageHTTPServer) gcAllServices(ctx context.Context) {
+	services, err := s.dbIns.ListServices(ctx, s.cluster)
+	if err != nil {
+		glog.Errorln("gcAllServices ListServices error", err)
+		return
+	}
+
+	for _, svc := range services {
+		if err := s.gcServiceConfigFiles(ctx, svc.ServiceUUID); err != nil {
+			glog.Errorln("gcServiceConfigFiles error", err, svc.ServiceUUID)
+		}
+	}
+}
+
+// gcServiceConfigFiles deletes every ConfigFile of serviceUUID that is not
+// referenced by any ServiceMember.Configs entry, skipping config files
+// younger than configFileGCGracePeriod. It also detects the reverse
+// inconsistency, a member referencing a missing FileID, and logs/emits a
+// metric for it rather than silently continuing.
+func (s *ManageHTTPServer) gcServiceConfigFiles(ctx context.Context, serviceUUID string) error {
+	members, err := s.dbIns.ListServiceMembers(ctx, serviceUUID)
+	if err != nil {
+		return err
+	}
+
+	referenced := make(map[string]bool)
+	for _, m := range members {
+		for _, cfg := range m.Configs {
+			referenced[cfg.FileID] = true
+		}
+	}
+
+	cfgFiles, err := s.dbIns.ListConfigFiles(ctx, serviceUUID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	existing := make(map[string]bool, len(cfgFiles))
+	for _, cfgfile := range cfgFiles {
+		existing[cfgfile.FileID] = true
+
+		if referenced[cfgfile.FileID] {
+			continue
+		}
+		if now.Sub(time.Unix(0, cfgfile.LastModified)) < configFileGCGracePeriod {
+			continue
+		}
+
+		glog.Infoln("gc orphaned config file", cfgfile.FileID, "service", serviceUUID)
+		if err := s.dbIns.DeleteConfigFile(ctx, serviceUUID, cfgfile.FileID); err != nil {
+			glog.Errorln("gc DeleteConfigFile error", err, cfgfile.FileID, "service", serviceUUID)
+			gcConfigFileDeleteErrors.Inc()
+			continue
+		}
+		gcConfigFilesDeletedTotal.Inc()
+	}
+
+	for fileID := range referenced {
+		if !existing[fileID] {
+			glog.Errorln("service member references missing config file", fileID, "service", serviceUUID)
+			gcMissingConfigFilesTotal.Inc()
+		}
+	}
+
+	return nil
+}
+
+// gcConfigFilesOneShot implements the REST endpoint that triggers a
+// one-shot gc pass for a single service, for operators who do not want to
+// wait for the next scheduled sweep.
+func (s *ManageHTTPServer) gcConfigFilesOneShot(ctx context.Context, r *http.Request, requuid string) merrors.Error {
+	req := &manage.ServiceCommonRequest{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		glog.Errorln("gcConfigFilesOneShot decode request error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	if req.Cluster != s.cluster || req.Region != s.region {
+		glog.Errorln("gcConfigFilesOneShot invalid request, local cluster", s.cluster,
+			"region", s.region, "requuid", requuid, req)
+		return merrors.BadRequest("cluster or region mismatch")
+	}
+
+	service, err := s.dbIns.GetService(ctx, s.cluster, req.ServiceName)
+	if err != nil {
+		glog.Errorln("gcConfigFilesOneShot GetService error", err, "requuid", requuid, req.ServiceName)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	if err := s.gcServiceConfigFiles(ctx, service.ServiceUUID); err != nil {
+		glog.Errorln("gcServiceConfigFiles error", err, "requuid", requuid, service.ServiceUUID)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	glog.Infoln("gc one-shot pass done for service", req.ServiceName, service.ServiceUUID, "requuid", requuid)
+	return nil
+}
@@ -0,0 +1,94 @@
+package manageserver
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/cloudstax/firecamp/common"
+	"github.com/cloudstax/firecamp/db"
+)
+
+// configUpdateMaxRetries bounds how many attempts in all, the first try
+// included, updateMemberConfigWithRetry makes to fetch and apply a config
+// mutation before surfacing a conditional-write conflict.
+const configUpdateMaxRetries = 5
+
+// configUpdateRetryBaseDelay is the starting backoff between retries; it
+// doubles on every attempt and is jittered to avoid two racing flows
+// retrying in lockstep.
+const configUpdateRetryBaseDelay = 50 * time.Millisecond
+
+// configMutator computes the desired config content from the content
+// currently on disk. ok is false when the desired state is already in
+// place, so updateMemberConfigWithRetry can stop without writing anything
+// -- this is what lets a retry after a conflict discover that a racing
+// flow already applied the same change.
+type configMutator func(content string) (newContent string, ok bool)
+
+// updateMemberConfigWithRetry re-fetches the ServiceMember named
+// memberName and the current content of its fileName config on every
+// attempt, re-applies mutate against that fresh content, and retries
+// updateMemberConfig on a conditional-write conflict (two init flows, or
+// an init flow and the drift reconciler, racing on the same member), for
+// up to configUpdateMaxRetries attempts in all with jittered backoff.
+func (s *ManageHTTPServer) updateMemberConfigWithRetry(ctx context.Context, serviceUUID string, memberName string,
+	fileName string, mutate configMutator, requuid string) error {
+	return retryOnConflict(requuid, "updateMemberConfig", func(attempt int) error {
+		member, err := s.dbIns.GetServiceMember(ctx, serviceUUID, memberName)
+		if err != nil {
+			return err
+		}
+
+		cfgIndex := -1
+		for i, c := range member.Configs {
+			if c.FileName == fileName {
+				cfgIndex = i
+				break
+			}
+		}
+		if cfgIndex < 0 {
+			glog.Errorln("member has no config file named", fileName, "requuid", requuid, member)
+			return common.ErrConfigMismatch
+		}
+
+		cfgfile, err := s.dbIns.GetConfigFile(ctx, serviceUUID, member.Configs[cfgIndex].FileID)
+		if err != nil {
+			return err
+		}
+
+		newContent, ok := mutate(cfgfile.Content)
+		if !ok {
+			return nil
+		}
+
+		return s.updateMemberConfig(ctx, member, cfgfile, cfgIndex, newContent, requuid)
+	})
+}
+
+// retryOnConflict runs fn, which should re-fetch whatever state it needs
+// and attempt one conditional write, up to configUpdateMaxRetries times
+// with jittered exponential backoff, stopping as soon as fn succeeds or
+// fails with anything other than a conditional-write conflict. This is
+// the shared skeleton behind updateMemberConfigWithRetry and
+// createRedisClusterFile: both re-fetch current state, attempt a
+// conditional update, and retry only on conflict.
+func retryOnConflict(requuid string, label string, fn func(attempt int) error) error {
+	delay := configUpdateRetryBaseDelay
+
+	for attempt := 0; ; attempt++ {
+		err := fn(attempt)
+		if err == nil {
+			return nil
+		}
+		if !db.IsConflictError(err) || attempt >= configUpdateMaxRetries-1 {
+			return err
+		}
+
+		glog.Errorln(label, "conflict, retry", attempt, "requuid", requuid, err)
+		time.Sleep(delay + time.Duration(rand.Int63n(int64(delay))))
+		delay *= 2
+	}
+}
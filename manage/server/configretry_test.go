@@ -0,0 +1,36 @@
+package manageserver
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRetryOnConflictSucceedsOnFirstAttempt(t *testing.T) {
+	calls := 0
+	err := retryOnConflict("requuid", "test", func(attempt int) error {
+		calls++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected fn to be called once, got %d", calls)
+	}
+}
+
+func TestRetryOnConflictReturnsImmediatelyOnNonConflictError(t *testing.T) {
+	wantErr := errors.New("not a conditional-write conflict")
+
+	calls := 0
+	err := retryOnConflict("requuid", "test", func(attempt int) error {
+		calls++
+		return wantErr
+	})
+	if err != wantErr {
+		t.Fatalf("expected %v, got %v", wantErr, err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected fn to be called once for a non-conflict error, got %d", calls)
+	}
+}
@@ -0,0 +1,174 @@
+package manageserver
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	consulapi "github.com/hashicorp/consul/api"
+	"golang.org/x/net/context"
+
+	"github.com/cloudstax/firecamp/catalog"
+	"github.com/cloudstax/firecamp/common"
+	"github.com/cloudstax/firecamp/dns"
+)
+
+// ConsulRegistrar registers and deregisters catalog service members as
+// Consul services, so applications can discover MongoDB/Kafka/Redis
+// members via Consul DNS/HTTP instead of only firecamp's own DNS. It is
+// only wired in when a Consul service already exists in the cluster; see
+// ManageHTTPServer.registerInConsul.
+type ConsulRegistrar struct {
+	client *consulapi.Client
+}
+
+// NewConsulRegistrar creates a ConsulRegistrar pointed at the given Consul
+// server addresses, e.g. the server IPs returned by createConsulService.
+func NewConsulRegistrar(consulAddr string) (*ConsulRegistrar, error) {
+	cfg := consulapi.DefaultConfig()
+	cfg.Address = consulAddr
+
+	client, err := consulapi.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConsulRegistrar{client: client}, nil
+}
+
+// RegisterService registers every member of a newly created (or scaled)
+// service as a Consul service, with a health check matching the
+// container platform's own health probe for that service.
+func (cr *ConsulRegistrar) RegisterService(domain string, serviceName string, members []*common.ServiceMember, port int64) error {
+	for _, m := range members {
+		reg := &consulapi.AgentServiceRegistration{
+			ID:      fmt.Sprintf("%s-%s", serviceName, m.MemberName),
+			Name:    serviceName,
+			Address: m.StaticIP,
+			Port:    int(port),
+			Check: &consulapi.AgentServiceCheck{
+				TCP:      fmt.Sprintf("%s:%d", m.StaticIP, port),
+				Interval: "10s",
+				Timeout:  "2s",
+			},
+		}
+
+		if err := cr.client.Agent().ServiceRegister(reg); err != nil {
+			return err
+		}
+
+		glog.Infoln("registered consul service", reg.ID, reg.Address, reg.Port)
+	}
+
+	return nil
+}
+
+// DeregisterService removes every member of a deleted service from Consul.
+func (cr *ConsulRegistrar) DeregisterService(serviceName string, members []*common.ServiceMember) error {
+	for _, m := range members {
+		id := fmt.Sprintf("%s-%s", serviceName, m.MemberName)
+		if err := cr.client.Agent().ServiceDeregister(id); err != nil {
+			return err
+		}
+		glog.Infoln("deregistered consul service", id)
+	}
+	return nil
+}
+
+// ReconcileMembership registers any new member and deregisters any member
+// no longer present. No code in the manage server calls it on a scale
+// event.
+func (cr *ConsulRegistrar) ReconcileMembership(domain string, serviceName string, members []*common.ServiceMember, port int64) error {
+	existing, err := cr.client.Agent().Services()
+	if err != nil {
+		return err
+	}
+
+	want := make(map[string]bool, len(members))
+	for _, m := range members {
+		want[fmt.Sprintf("%s-%s", serviceName, m.MemberName)] = true
+	}
+
+	for id, svc := range existing {
+		if svc.Service == serviceName && !want[id] {
+			if err := cr.client.Agent().ServiceDeregister(id); err != nil {
+				return err
+			}
+			glog.Infoln("reconcile: deregistered stale consul service", id)
+		}
+	}
+
+	return cr.RegisterService(domain, serviceName, members, port)
+}
+
+// consulDefaultHTTPPort is the Consul agent's default HTTP API port,
+// which is what ConsulRegistrar talks to.
+const consulDefaultHTTPPort = 8500
+
+// setConsulRegistrar builds a ConsulRegistrar pointed at a just-created
+// Consul service's members and caches it for registerInConsul, so every
+// catalog service created after the cluster's Consul service exists gets
+// registered. It is a no-op once a registrar already exists: the cluster
+// only ever has one Consul service, so the first call wins.
+func (s *ManageHTTPServer) setConsulRegistrar(serverIPs []string, requuid string) {
+	if len(serverIPs) == 0 {
+		return
+	}
+
+	s.consulMu.Lock()
+	defer s.consulMu.Unlock()
+
+	if s.consulRegistrar != nil {
+		return
+	}
+
+	addr := fmt.Sprintf("%s:%d", serverIPs[0], consulDefaultHTTPPort)
+	registrar, err := NewConsulRegistrar(addr)
+	if err != nil {
+		glog.Errorln("NewConsulRegistrar error", err, addr, "requuid", requuid)
+		return
+	}
+
+	s.consulRegistrar = registrar
+	glog.Infoln("consul registrar ready", addr, "requuid", requuid)
+}
+
+// registerInConsul registers every member of a just-created service with
+// Consul, when a Consul service already exists in the cluster. It is
+// called from CreateCommonService right after the service and its members
+// are created, and is a best-effort step: a failure here does not fail
+// the surrounding create request, since the service is already usable via
+// firecamp's own DNS.
+func (s *ManageHTTPServer) registerInConsul(ctx context.Context, serviceUUID string, requuid string) {
+	s.consulMu.Lock()
+	registrar := s.consulRegistrar
+	s.consulMu.Unlock()
+
+	if registrar == nil {
+		return
+	}
+
+	attr, err := s.dbIns.GetServiceAttr(ctx, serviceUUID)
+	if err != nil {
+		glog.Errorln("registerInConsul GetServiceAttr error", err, serviceUUID, "requuid", requuid)
+		return
+	}
+
+	members, err := s.dbIns.ListServiceMembers(ctx, serviceUUID)
+	if err != nil {
+		glog.Errorln("registerInConsul ListServiceMembers error", err, serviceUUID, "requuid", requuid)
+		return
+	}
+
+	if attr.ServiceType == catalog.CatalogService_Consul {
+		// Consul does not register itself.
+		return
+	}
+
+	domain := dns.GenDefaultDomainName(s.cluster)
+	if err := registrar.RegisterService(domain, attr.ServiceName, members, attr.ServicePort); err != nil {
+		glog.Errorln("registerInConsul RegisterService error", err, serviceUUID, "requuid", requuid)
+		return
+	}
+
+	glog.Infoln("registered service in consul", attr.ServiceName, serviceUUID, "requuid", requuid)
+}
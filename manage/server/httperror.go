@@ -0,0 +1,45 @@
+package manageserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/golang/glog"
+
+	merrors "github.com/cloudstax/firecamp/manage/errors"
+)
+
+// errorResponse is the JSON body writeError sends for every non-2xx
+// response, replacing the old http.Error(w, text, code) plain-text body so
+// a caller gets a stable machine-readable code and the underlying error
+// instead of just a generic status text.
+type errorResponse struct {
+	Code     string               `json:"code"`
+	Message  string               `json:"message"`
+	RequUUID string               `json:"requuid"`
+	Details  []merrors.FieldError `json:"details,omitempty"`
+}
+
+// writeError writes err as a JSON errorResponse with the HTTP status err
+// maps to. putOp/getOp/delOp and every handler they dispatch to funnel
+// their error result through here instead of calling http.Error directly.
+func writeError(w http.ResponseWriter, requuid string, err merrors.Error) {
+	resp := errorResponse{
+		Code:     err.Code(),
+		Message:  err.Error(),
+		RequUUID: requuid,
+	}
+	if verr, ok := err.(*merrors.ValidationError); ok {
+		resp.Details = verr.Details
+	}
+
+	b, merr := json.Marshal(resp)
+	if merr != nil {
+		glog.Errorln("Marshal errorResponse error", merr, "requuid", requuid)
+		http.Error(w, err.Error(), err.Status())
+		return
+	}
+
+	w.WriteHeader(err.Status())
+	w.Write(b)
+}
@@ -0,0 +1,165 @@
+package manageserver
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudstax/firecamp/common"
+	"github.com/cloudstax/firecamp/manage"
+)
+
+// attrFetchConcurrency bounds how many GetServiceAttr calls listServices
+// runs at once, so a large, unfiltered listing does not open one call per
+// service against the db.
+const attrFetchConcurrency = 8
+
+// paginateServices returns up to maxKeys of services (sorted by
+// ServiceName for a stable cursor) starting just after nextToken, plus the
+// token to pass back in for the next page, or "" if this was the last
+// page. db.DB has no call that pages through services server-side, only
+// ListServices for the full listing, so the pagination itself happens here
+// instead. A maxKeys of 0 returns every remaining service in one page.
+func paginateServices(services []*common.Service, maxKeys int64, nextToken string) ([]*common.Service, string) {
+	sorted := make([]*common.Service, len(services))
+	copy(sorted, services)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ServiceName < sorted[j].ServiceName })
+
+	start := 0
+	if len(nextToken) > 0 {
+		start = sort.Search(len(sorted), func(i int) bool { return sorted[i].ServiceName > nextToken })
+	}
+	if start >= len(sorted) {
+		return nil, ""
+	}
+
+	end := len(sorted)
+	if maxKeys > 0 && start+int(maxKeys) < end {
+		end = start + int(maxKeys)
+	}
+
+	page := sorted[start:end]
+	newToken := ""
+	if end < len(sorted) {
+		newToken = page[len(page)-1].ServiceName
+	}
+	return page, newToken
+}
+
+// matchServiceAttrFilter reports whether attr satisfies every filter field
+// set on req: Prefix against the service name, Labels as an exact subset
+// match, Status against the service's current status, and CreatedAfter as
+// a lower bound on the service's creation time. A zero-value field is not
+// filtered on.
+func matchServiceAttrFilter(name string, attr *common.ServiceAttr, req *manage.ListServiceRequest) bool {
+	if len(req.Prefix) > 0 && !strings.HasPrefix(name, req.Prefix) {
+		return false
+	}
+	if len(req.Status) > 0 && string(attr.ServiceStatus) != req.Status {
+		return false
+	}
+	if req.CreatedAfter > 0 && attr.CreatedTime < req.CreatedAfter {
+		return false
+	}
+	for k, v := range req.Labels {
+		if attr.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// fetchServiceAttrs fetches GetServiceAttr for every service in services
+// that matches req's filters, using a bounded worker pool tied to ctx so a
+// large cluster listing does not serialize one round trip per service; it
+// stops launching new fetches and returns the first error seen, if any.
+func (s *ManageHTTPServer) fetchServiceAttrs(ctx context.Context, services []*common.Service, req *manage.ListServiceRequest) ([]*common.ServiceAttr, error) {
+	fetchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		idx  int
+		attr *common.ServiceAttr
+	}
+
+	sem := make(chan struct{}, attrFetchConcurrency)
+	resCh := make(chan result, len(services))
+	errCh := make(chan error, 1)
+
+	var wg sync.WaitGroup
+	for i, svc := range services {
+		select {
+		case <-fetchCtx.Done():
+		default:
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, svc *common.Service) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				attr, err := s.dbIns.GetServiceAttr(fetchCtx, svc.ServiceUUID)
+				if err != nil {
+					select {
+					case errCh <- err:
+						cancel()
+					default:
+					}
+					return
+				}
+				if matchServiceAttrFilter(svc.ServiceName, attr, req) {
+					resCh <- result{i, attr}
+				}
+			}(i, svc)
+		}
+	}
+	wg.Wait()
+	close(resCh)
+
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
+	}
+
+	attrs := make([]*common.ServiceAttr, 0, len(services))
+	ordered := make([]*common.ServiceAttr, len(services))
+	for r := range resCh {
+		ordered[r.idx] = r.attr
+	}
+	for _, attr := range ordered {
+		if attr != nil {
+			attrs = append(attrs, attr)
+		}
+	}
+	return attrs, nil
+}
+
+// projectFields returns v's JSON representation trimmed down to fields,
+// so a client asking for e.g. Fields: ["ServiceName"] does not pay for the
+// rest of a large attribute set across a big listing. An empty fields
+// returns every field unchanged.
+func projectFields(v interface{}, fields []string) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	full := make(map[string]interface{})
+	if err := json.Unmarshal(b, &full); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return full, nil
+	}
+
+	projected := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if val, ok := full[f]; ok {
+			projected[f] = val
+		}
+	}
+	return projected, nil
+}
@@ -0,0 +1,95 @@
+package manageserver
+
+import (
+	"testing"
+
+	"github.com/cloudstax/firecamp/common"
+	"github.com/cloudstax/firecamp/manage"
+)
+
+func svc(name string) *common.Service {
+	return &common.Service{ServiceName: name}
+}
+
+func TestPaginateServicesSortsAndPages(t *testing.T) {
+	services := []*common.Service{svc("c"), svc("a"), svc("b")}
+
+	page, next := paginateServices(services, 2, "")
+	if len(page) != 2 || page[0].ServiceName != "a" || page[1].ServiceName != "b" {
+		t.Fatalf("unexpected first page %v", names2(page))
+	}
+	if next != "b" {
+		t.Fatalf("expected next token %q, got %q", "b", next)
+	}
+
+	page, next = paginateServices(services, 2, next)
+	if len(page) != 1 || page[0].ServiceName != "c" {
+		t.Fatalf("unexpected second page %v", names2(page))
+	}
+	if next != "" {
+		t.Fatalf("expected no further page, got token %q", next)
+	}
+}
+
+func TestPaginateServicesZeroMaxKeysReturnsAll(t *testing.T) {
+	services := []*common.Service{svc("b"), svc("a")}
+
+	page, next := paginateServices(services, 0, "")
+	if len(page) != 2 {
+		t.Fatalf("expected all %d services, got %d", len(services), len(page))
+	}
+	if next != "" {
+		t.Fatalf("expected no further page, got token %q", next)
+	}
+}
+
+func TestPaginateServicesPastEndReturnsEmpty(t *testing.T) {
+	services := []*common.Service{svc("a")}
+
+	page, next := paginateServices(services, 10, "a")
+	if len(page) != 0 || next != "" {
+		t.Fatalf("expected empty page past the end, got %v, token %q", names2(page), next)
+	}
+}
+
+func names2(services []*common.Service) []string {
+	out := make([]string, len(services))
+	for i, s := range services {
+		out[i] = s.ServiceName
+	}
+	return out
+}
+
+func TestMatchServiceAttrFilterPrefix(t *testing.T) {
+	attr := &common.ServiceAttr{}
+	if !matchServiceAttrFilter("mysvc-1", attr, &manage.ListServiceRequest{Prefix: "mysvc-"}) {
+		t.Error("expected name with matching prefix to match")
+	}
+	if matchServiceAttrFilter("other-1", attr, &manage.ListServiceRequest{Prefix: "mysvc-"}) {
+		t.Error("expected name without matching prefix not to match")
+	}
+}
+
+func TestMatchServiceAttrFilterLabels(t *testing.T) {
+	attr := &common.ServiceAttr{Labels: map[string]string{"env": "prod", "team": "db"}}
+
+	req := &manage.ListServiceRequest{Labels: map[string]string{"env": "prod"}}
+	if !matchServiceAttrFilter("svc", attr, req) {
+		t.Error("expected a subset label match to match")
+	}
+
+	req = &manage.ListServiceRequest{Labels: map[string]string{"env": "staging"}}
+	if matchServiceAttrFilter("svc", attr, req) {
+		t.Error("expected a mismatched label value not to match")
+	}
+}
+
+func TestMatchServiceAttrFilterCreatedAfter(t *testing.T) {
+	attr := &common.ServiceAttr{CreatedTime: 100}
+	if matchServiceAttrFilter("svc", attr, &manage.ListServiceRequest{CreatedAfter: 200}) {
+		t.Error("expected an older service not to match a newer CreatedAfter bound")
+	}
+	if !matchServiceAttrFilter("svc", attr, &manage.ListServiceRequest{CreatedAfter: 50}) {
+		t.Error("expected a newer service to match an older CreatedAfter bound")
+	}
+}
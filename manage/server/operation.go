@@ -0,0 +1,205 @@
+package manageserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/cloudstax/firecamp/manage"
+	merrors "github.com/cloudstax/firecamp/manage/errors"
+)
+
+// defaultWaitOperationTimeout bounds how long a "X-Wait: true" call and a
+// WaitOperationOp call without TimeoutSeconds block before falling back
+// to a result the caller can poll for.
+const defaultWaitOperationTimeout = 5 * time.Minute
+
+// runAsync starts an Operation for resourceURL and runs work in its own
+// goroutine with the Operation's cancelable context, so cancelOperation
+// propagates cleanly to whatever containersvc/db/dns calls work is
+// blocked on. It returns a 202 with the Operation immediately, unless the
+// caller set "X-Wait: true", in which case it blocks for up to
+// defaultWaitOperationTimeout and returns work's own result instead.
+func (s *ManageHTTPServer) runAsync(ctx context.Context, w http.ResponseWriter, r *http.Request, resourceURL string,
+	work func(ctx context.Context) (body []byte, err merrors.Error)) merrors.Error {
+	// ServeHTTP cancels the request ctx once the handler returns, so the
+	// Operation's context is not derived from it: only cancelOperation
+	// cancels the work.
+	op, opCtx := s.ops.Start(context.Background(), s.cluster, resourceURL)
+
+	type outcome struct {
+		body []byte
+		err  merrors.Error
+	}
+	done := make(chan outcome, 1)
+
+	go func() {
+		s.ops.SetRunning(op)
+		body, err := work(opCtx)
+
+		var runErr error
+		if err != nil {
+			runErr = fmt.Errorf("%s", err.Error())
+			if opCtx.Err() == context.Canceled {
+				runErr = context.Canceled
+			}
+		}
+		s.ops.Finish(op, json.RawMessage(body), runErr)
+
+		done <- outcome{body, err}
+	}()
+
+	if strings.EqualFold(r.Header.Get("X-Wait"), "true") {
+		select {
+		case res := <-done:
+			if res.err != nil {
+				return res.err
+			}
+			if len(res.body) > 0 {
+				w.WriteHeader(http.StatusOK)
+				w.Write(res.body)
+			}
+			return nil
+		case <-time.After(defaultWaitOperationTimeout):
+			// still running past the synchronous budget; fall through and
+			// hand the caller the operation to poll instead.
+		}
+	}
+
+	return s.writeOperationAccepted(ctx, w, op.ID)
+}
+
+// writeOperationAccepted writes the 202 response every runAsync call
+// returns when it does not complete (or is not asked to wait) within
+// defaultWaitOperationTimeout.
+func (s *ManageHTTPServer) writeOperationAccepted(ctx context.Context, w http.ResponseWriter, opID string) merrors.Error {
+	op, err := s.ops.Get(ctx, opID)
+	if err != nil {
+		glog.Errorln("Get operation error", err, opID)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	b, err := json.Marshal(&manage.GetOperationResponse{Operation: op})
+	if err != nil {
+		glog.Errorln("Marshal GetOperationResponse error", err, opID)
+		return merrors.Internal(err.Error())
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(b)
+
+	return nil
+}
+
+// getOperation implements manage.GetOperationOp.
+func (s *ManageHTTPServer) getOperation(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
+	req := &manage.GetOperationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		glog.Errorln("GetOperationRequest decode error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	op, err := s.ops.Get(ctx, req.OperationID)
+	if err != nil {
+		glog.Errorln("Get operation error", err, "requuid", requuid, req.OperationID)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	return s.writeJSON(w, &manage.GetOperationResponse{Operation: op}, requuid)
+}
+
+// listOperations implements manage.ListOperationsOp.
+func (s *ManageHTTPServer) listOperations(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
+	req := &manage.ListOperationsRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		glog.Errorln("ListOperationsRequest decode error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	if req.Cluster != s.cluster || req.Region != s.region {
+		glog.Errorln("ListOperationsRequest invalid request, local cluster", s.cluster,
+			"region", s.region, "requuid", requuid, req)
+		return merrors.BadRequest("cluster or region mismatch")
+	}
+
+	ops, err := s.ops.List(ctx, s.cluster)
+	if err != nil {
+		glog.Errorln("List operations error", err, "requuid", requuid)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	resp := &manage.ListOperationsResponse{Operations: ops}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		glog.Errorln("Marshal ListOperationsResponse error", err, "requuid", requuid)
+		return merrors.Internal(err.Error())
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+
+	return nil
+}
+
+// cancelOperation implements manage.CancelOperationOp.
+func (s *ManageHTTPServer) cancelOperation(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
+	req := &manage.CancelOperationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		glog.Errorln("CancelOperationRequest decode error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	if err := s.ops.Cancel(req.OperationID); err != nil {
+		glog.Errorln("Cancel operation error", err, "requuid", requuid, req.OperationID)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	glog.Infoln("canceled operation", req.OperationID, "requuid", requuid)
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// waitOperation implements manage.WaitOperationOp: it long-polls for the
+// operation to reach a terminal status, bounded by TimeoutSeconds (or
+// defaultWaitOperationTimeout if unset), and returns its state either way.
+func (s *ManageHTTPServer) waitOperation(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
+	req := &manage.WaitOperationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		glog.Errorln("WaitOperationRequest decode error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	timeout := defaultWaitOperationTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+
+	op, err := s.ops.Wait(ctx, req.OperationID, timeout)
+	if err != nil {
+		glog.Errorln("Wait operation error", err, "requuid", requuid, req.OperationID)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	return s.writeJSON(w, &manage.WaitOperationResponse{Operation: op}, requuid)
+}
+
+// writeJSON marshals resp and writes it as a 200 response, the shared
+// tail of every operation tracking handler above.
+func (s *ManageHTTPServer) writeJSON(w http.ResponseWriter, resp interface{}, requuid string) merrors.Error {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		glog.Errorln("Marshal response error", err, "requuid", requuid)
+		return merrors.Internal(err.Error())
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package manageserver
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudstax/firecamp/operations"
+)
+
+// operationStore is the operations.DBOps Manager mirrors Operation state
+// to. db.DB does not implement DBOps in this build, so operation history
+// lives only in this in-memory map instead of a durable store; an
+// operation started before a manage server restart cannot be polled again
+// after one, the same as any other in-flight goroutine.
+type operationStore struct {
+	mu  sync.Mutex
+	ops map[string]*operations.Operation
+}
+
+func newOperationStore() *operationStore {
+	return &operationStore{ops: make(map[string]*operations.Operation)}
+}
+
+func (s *operationStore) CreateOperation(ctx context.Context, op *operations.Operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ops[op.ID] = op
+	return nil
+}
+
+func (s *operationStore) UpdateOperation(ctx context.Context, op *operations.Operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ops[op.ID] = op
+	return nil
+}
+
+func (s *operationStore) GetOperation(ctx context.Context, id string) (*operations.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, ok := s.ops[id]
+	if !ok {
+		return nil, fmt.Errorf("operation %s not found", id)
+	}
+	return op, nil
+}
+
+func (s *operationStore) ListOperations(ctx context.Context, cluster string) ([]*operations.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ops := make([]*operations.Operation, 0)
+	for _, op := range s.ops {
+		if op.Cluster == cluster {
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
@@ -0,0 +1,221 @@
+package manageserver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudstax/firecamp/catalog"
+	"github.com/cloudstax/firecamp/catalog/consul"
+	"github.com/cloudstax/firecamp/catalog/mongodb"
+	"github.com/cloudstax/firecamp/catalog/redis"
+	"github.com/cloudstax/firecamp/common"
+	"github.com/cloudstax/firecamp/dns"
+	"github.com/cloudstax/firecamp/manage"
+)
+
+// reconcileInterval is how often StartDriftReconciler re-derives every
+// service's expected config and compares it against what is on disk.
+const reconcileInterval = 10 * time.Minute
+
+var (
+	driftDetectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "firecamp_manageserver_drift_detected_total",
+		Help: "Total number of services found with config drift by the reconciler.",
+	}, []string{"service_type"})
+	reconcileErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "firecamp_manageserver_reconcile_errors_total",
+		Help: "Total number of errors while reconciling a service's config.",
+	}, []string{"service_type"})
+)
+
+func init() {
+	prometheus.MustRegister(driftDetectedTotal, reconcileErrorsTotal)
+}
+
+// reconcilePaused tracks which services an operator has frozen out of
+// drift reconciliation. common.ServiceAttr has no field for this (it is
+// the durable service record, maintained outside this package), so the
+// pause is in-memory only and does not survive a manage server restart;
+// PauseReconcile needs to be called again after a restart if the freeze
+// should still apply.
+var (
+	reconcilePausedMu sync.Mutex
+	reconcilePaused   = make(map[string]bool)
+)
+
+// PauseReconcile freezes drift reconciliation for serviceUUID, e.g. while
+// an operator is making a manual config change that would otherwise look
+// like drift.
+func PauseReconcile(serviceUUID string) {
+	reconcilePausedMu.Lock()
+	defer reconcilePausedMu.Unlock()
+	reconcilePaused[serviceUUID] = true
+}
+
+// ResumeReconcile un-freezes drift reconciliation for serviceUUID.
+func ResumeReconcile(serviceUUID string) {
+	reconcilePausedMu.Lock()
+	defer reconcilePausedMu.Unlock()
+	delete(reconcilePaused, serviceUUID)
+}
+
+func isReconcilePaused(serviceUUID string) bool {
+	reconcilePausedMu.Lock()
+	defer reconcilePausedMu.Unlock()
+	return reconcilePaused[serviceUUID]
+}
+
+// StartDriftReconciler periodically re-derives the expected config for
+// every service (auth-enabled, cluster-announce-ip set to
+// member.StaticIP, Consul member DNS->IP map) and, if the rendered config
+// on disk has drifted from it, applies a targeted updateMemberConfig and
+// rolling restart within the same pass. Redis cluster.info node IDs are
+// not compared. It skips any service
+// paused via PauseReconcile, so operators can freeze a service during
+// manual maintenance.
+func (s *ManageHTTPServer) StartDriftReconciler(ctx context.Context) {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reconcileAllServices(ctx)
+		}
+	}
+}
+
+func (s *ManageHTTPServer) reconcileAllServices(ctx context.Context) {
+	services, err := s.dbIns.ListServices(ctx, s.cluster)
+	if err != nil {
+		glog.Errorln("reconcileAllServices ListServices error", err)
+		return
+	}
+
+	for _, svc := range services {
+		attr, err := s.dbIns.GetServiceAttr(ctx, svc.ServiceUUID)
+		if err != nil {
+			glog.Errorln("reconcile GetServiceAttr error", err, svc.ServiceUUID)
+			continue
+		}
+
+		if isReconcilePaused(svc.ServiceUUID) {
+			glog.V(2).Infoln("reconcile paused for service", svc.ServiceUUID)
+			continue
+		}
+
+		if err := s.reconcileService(ctx, attr); err != nil {
+			glog.Errorln("reconcileService error", err, svc.ServiceUUID, attr.ServiceType)
+			reconcileErrorsTotal.WithLabelValues(attr.ServiceType).Inc()
+		}
+	}
+}
+
+// reconcileService re-derives the expected config for attr's members and,
+// on drift, rewrites the config and schedules a rolling restart. It is
+// idempotent: a no-drift pass makes no db writes and restarts nothing.
+func (s *ManageHTTPServer) reconcileService(ctx context.Context, attr *common.ServiceAttr) error {
+	members, err := s.dbIns.ListServiceMembers(ctx, attr.ServiceUUID)
+	if err != nil {
+		return err
+	}
+
+	var memberips map[string]string
+	if attr.ServiceType == catalog.CatalogService_Consul {
+		domain := dns.GenDefaultDomainName(s.cluster)
+		memberips = make(map[string]string)
+		for _, m := range members {
+			memberips[dns.GenDNSName(m.MemberName, domain)] = m.StaticIP
+		}
+	}
+
+	drifted := make([]*common.ServiceMember, 0)
+
+	for _, member := range members {
+		for i, cfg := range member.Configs {
+			newContent, hasDrift, err := s.expectedConfigContent(ctx, attr, member, cfg, memberips)
+			if err != nil {
+				return err
+			}
+			if !hasDrift {
+				continue
+			}
+
+			cfgfile, err := s.dbIns.GetConfigFile(ctx, member.ServiceUUID, cfg.FileID)
+			if err != nil {
+				return err
+			}
+
+			if err := s.updateMemberConfig(ctx, member, cfgfile, i, newContent, "reconciler"); err != nil {
+				return err
+			}
+
+			drifted = append(drifted, member)
+		}
+	}
+
+	if len(drifted) == 0 {
+		return nil
+	}
+
+	driftDetectedTotal.WithLabelValues(attr.ServiceType).Inc()
+	glog.Infoln("drift detected and corrected for", len(drifted), "members of service", attr.ServiceUUID, attr.ServiceType)
+
+	switch attr.ServiceType {
+	case catalog.CatalogService_MongoDB:
+		return s.RollingRestartService(ctx, attr.ServiceUUID, attr.ServiceName, drifted, mongodbcatalog.CheckMemberHealth, manage.RollingRestartAbortOnFailure, 0)
+	case catalog.CatalogService_Redis:
+		return s.RollingRestartService(ctx, attr.ServiceUUID, attr.ServiceName, drifted, rediscatalog.CheckMemberHealth, manage.RollingRestartAbortOnFailure, 0)
+	default:
+		return s.containersvcIns.RestartService(ctx, s.cluster, attr.ServiceName, attr.Replicas)
+	}
+}
+
+// expectedConfigContent re-derives what cfg's content should be for
+// member, and reports whether it differs from the content currently
+// stored. The same per-config helpers the init flows use
+// (mongodbcatalog.IsAuthEnabled/EnableMongoDBAuth,
+// rediscatalog.NeedToSetClusterAnnounceIP/SetClusterAnnounceIP,
+// consulcatalog.ReplaceMemberName) drive the comparison, so drift
+// detection and initial config rendering never disagree.
+func (s *ManageHTTPServer) expectedConfigContent(ctx context.Context, attr *common.ServiceAttr,
+	member *common.ServiceMember, cfg *common.MemberConfig, consulMemberIPs map[string]string) (newContent string, hasDrift bool, err error) {
+	cfgfile, err := s.dbIns.GetConfigFile(ctx, member.ServiceUUID, cfg.FileID)
+	if err != nil {
+		return "", false, err
+	}
+
+	switch {
+	case attr.ServiceType == catalog.CatalogService_MongoDB && mongodbcatalog.IsMongoDBConfFile(cfg.FileName):
+		if mongodbcatalog.IsAuthEnabled(cfgfile.Content) {
+			return "", false, nil
+		}
+		return mongodbcatalog.EnableMongoDBAuth(cfgfile.Content), true, nil
+
+	case attr.ServiceType == catalog.CatalogService_Redis && rediscatalog.IsRedisConfFile(cfg.FileName):
+		content := cfgfile.Content
+		drift := false
+		if rediscatalog.NeedToEnableAuth(content) {
+			content = rediscatalog.EnableRedisAuth(content)
+			drift = true
+		}
+		if rediscatalog.NeedToSetClusterAnnounceIP(content) {
+			content = rediscatalog.SetClusterAnnounceIP(content, member.StaticIP)
+			drift = true
+		}
+		return content, drift, nil
+
+	case attr.ServiceType == catalog.CatalogService_Consul && consulcatalog.IsBasicConfigFile(cfg.FileName):
+		newContent := consulcatalog.ReplaceMemberName(cfgfile.Content, consulMemberIPs)
+		return newContent, newContent != cfgfile.Content, nil
+
+	default:
+		return "", false, nil
+	}
+}
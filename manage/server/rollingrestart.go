@@ -0,0 +1,97 @@
+package manageserver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/cloudstax/firecamp/common"
+	"github.com/cloudstax/firecamp/manage"
+)
+
+// memberReadyCheck reports whether one member of a service is ready to
+// serve, e.g. db.serverStatus() for MongoDB, CLUSTER INFO state:ok for
+// Redis, nodetool status UN for Cassandra. It is looked up per catalog
+// service kind, so RollingRestartService stays generic.
+type memberReadyCheck func(ctx context.Context, member *common.ServiceMember) (ready bool, err error)
+
+// memberReadyCheckTimeout bounds how long RollingRestartService waits for
+// a single member to report ready before treating it as failed.
+const memberReadyCheckTimeout = 5 * time.Minute
+
+// memberReadyPollInterval is how often a restarted member is re-probed
+// while waiting for it to become ready.
+const memberReadyPollInterval = 10 * time.Second
+
+// RollingRestartService restarts serviceName one member at a time: it
+// restarts a single member via RestartServiceMember, waits for check to
+// report it ready, and only then restarts the next member, so the service
+// as a whole keeps serving throughout instead of dropping every member at
+// once and waiting for all of them to come back.
+func (s *ManageHTTPServer) RollingRestartService(ctx context.Context, serviceUUID string, serviceName string,
+	members []*common.ServiceMember, check memberReadyCheck, policy manage.RollingRestartFailurePolicy, maxFailures int) error {
+	if maxFailures <= 0 {
+		maxFailures = manage.DefaultRollingRestartMaxFailures
+	}
+
+	s.catalogSvcInit.UpdateTaskStatusMsg(serviceUUID, fmt.Sprintf("restarting %d members", len(members)))
+
+	failures := 0
+	for i, member := range members {
+		statusMsg := fmt.Sprintf("restarting member %s (%d/%d)", member.MemberName, i+1, len(members))
+		s.catalogSvcInit.UpdateTaskStatusMsg(serviceUUID, statusMsg)
+
+		if err := s.containersvcIns.RestartServiceMember(ctx, s.cluster, serviceName, member.MemberName); err != nil {
+			glog.Errorln("RestartServiceMember error", err, member.MemberName, serviceName, serviceUUID)
+			return err
+		}
+
+		statusMsg = fmt.Sprintf("waiting for member %s to be ready (%d/%d)", member.MemberName, i+1, len(members))
+		s.catalogSvcInit.UpdateTaskStatusMsg(serviceUUID, statusMsg)
+
+		ready, err := s.waitMemberReady(ctx, member, check)
+		if err != nil || !ready {
+			glog.Errorln("member did not become ready after restart", member.MemberName, serviceUUID, "error", err)
+			if policy != manage.RollingRestartContinuePastFailures || failures >= maxFailures {
+				if err == nil {
+					err = fmt.Errorf("member %s did not become ready after restart", member.MemberName)
+				}
+				return err
+			}
+			failures++
+			continue
+		}
+
+		glog.Infoln("member ready after restart", member.MemberName, serviceUUID)
+	}
+
+	if failures > 0 {
+		glog.Errorln("rolling restart completed with", failures, "member failures", serviceUUID)
+	}
+
+	return nil
+}
+
+func (s *ManageHTTPServer) waitMemberReady(ctx context.Context, member *common.ServiceMember, check memberReadyCheck) (bool, error) {
+	deadline := time.Now().Add(memberReadyCheckTimeout)
+	for {
+		ready, err := check(ctx, member)
+		if err != nil {
+			return false, err
+		}
+		if ready {
+			return true, nil
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(memberReadyPollInterval):
+		}
+	}
+}
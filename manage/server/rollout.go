@@ -0,0 +1,82 @@
+package manageserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/cloudstax/firecamp/manage"
+	merrors "github.com/cloudstax/firecamp/manage/errors"
+)
+
+// updateService implements manage.UpdateServiceOp: for RolloutRecreate (or
+// no Rollout at all) it updates the service's container image in place.
+// RolloutBlueGreen and RolloutCanary need a DNS provider that supports
+// weighted records to split traffic between a stable and a canary backing
+// service, which this build does not have, so those are rejected up front
+// instead of accepting a rollout request that can never progress past
+// registering the two services.
+func (s *ManageHTTPServer) updateService(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
+	req := &manage.UpdateServiceRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		glog.Errorln("UpdateServiceRequest decode error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
+		glog.Errorln("UpdateServiceRequest invalid request, local cluster", s.cluster,
+			"region", s.region, "requuid", requuid, req.Service)
+		return merrors.BadRequest("cluster or region mismatch")
+	}
+
+	if req.Rollout != nil && req.Rollout.Type != manage.RolloutRecreate {
+		glog.Errorln("updateService rollout type", req.Rollout.Type, "needs DNS weighted-record support"+
+			" not available in this build, requuid", requuid, req.Service.ServiceName)
+		return merrors.New("NotImplemented", http.StatusNotImplemented,
+			fmt.Sprintf("rollout type %s is not implemented: no DNS weighted-record support", req.Rollout.Type))
+	}
+
+	servicename := req.Service.ServiceName
+
+	return s.runAsync(ctx, w, r, "/"+servicename, func(ctx context.Context) (body []byte, err merrors.Error) {
+		if err := s.containersvcIns.UpdateServiceImage(ctx, s.cluster, servicename, req.ContainerImage); err != nil {
+			glog.Errorln("UpdateServiceImage error", err, servicename, "requuid", requuid)
+			return nil, manage.ConvertToHTTPError(err)
+		}
+
+		glog.Infoln("updated service", servicename, "to image", req.ContainerImage, "requuid", requuid)
+		return nil, nil
+	})
+}
+
+// promoteService implements manage.PromoteServiceOp. It only makes sense
+// for a BlueGreen or Canary rollout in progress, and updateService cannot
+// start either in this build (see updateService), so there is never one
+// to promote.
+func (s *ManageHTTPServer) promoteService(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
+	req := &manage.PromoteServiceRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		glog.Errorln("PromoteServiceRequest decode error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	glog.Errorln("promoteService, no rollout in progress for", req.Service.ServiceName, "requuid", requuid)
+	return merrors.BadRequest("no rollout in progress for " + req.Service.ServiceName)
+}
+
+// abortService implements manage.AbortServiceOp. Same reasoning as
+// promoteService: there is never a rollout in progress to abort in this
+// build.
+func (s *ManageHTTPServer) abortService(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
+	req := &manage.AbortServiceRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		glog.Errorln("AbortServiceRequest decode error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	glog.Errorln("abortService, no rollout in progress for", req.Service.ServiceName, "requuid", requuid)
+	return merrors.BadRequest("no rollout in progress for " + req.Service.ServiceName)
+}
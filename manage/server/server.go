@@ -1,4 +1,4 @@
-package managehttpserver
+package manageserver
 
 import (
 	"encoding/json"
@@ -6,19 +6,25 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/net/context"
 
 	"github.com/golang/glog"
-
-	"github.com/openconnectio/openmanage/common"
-	"github.com/openconnectio/openmanage/containersvc"
-	"github.com/openconnectio/openmanage/db"
-	"github.com/openconnectio/openmanage/dns"
-	"github.com/openconnectio/openmanage/manage"
-	"github.com/openconnectio/openmanage/manage/service"
-	"github.com/openconnectio/openmanage/server"
-	"github.com/openconnectio/openmanage/utils"
+	"go.uber.org/zap"
+
+	"github.com/cloudstax/firecamp/common"
+	"github.com/cloudstax/firecamp/containersvc"
+	"github.com/cloudstax/firecamp/db"
+	"github.com/cloudstax/firecamp/dns"
+	"github.com/cloudstax/firecamp/manage"
+	merrors "github.com/cloudstax/firecamp/manage/errors"
+	"github.com/cloudstax/firecamp/manage/service"
+	"github.com/cloudstax/firecamp/operations"
+	"github.com/cloudstax/firecamp/pkg/log"
+	"github.com/cloudstax/firecamp/server"
+	"github.com/cloudstax/firecamp/utils"
 )
 
 // The ManageHTTPServer is the management http server for the service management.
@@ -47,12 +53,37 @@ type ManageHTTPServer struct {
 	serverInfo      server.Info
 	containersvcIns containersvc.ContainerSvc
 	svc             *manageservice.ManageService
+
+	authenticator Authenticator
+	auditSink     AuditSink
+	ops           *operations.Manager
+	logger        *zap.Logger
+
+	// bgCancel stops the background loops (StartConfigFileGC,
+	// StartBackupScheduler and StartDriftReconciler) started by
+	// NewManageHTTPServer. Close calls it.
+	bgCancel context.CancelFunc
+
+	// consulRegistrar is set the first time a Consul catalog service is
+	// created in the cluster (see setConsulRegistrar), and stays nil
+	// until then: registerInConsul is a no-op for a cluster with no
+	// Consul service. consulMu guards the lazy assignment, since creates
+	// can run concurrently.
+	consulMu        sync.Mutex
+	consulRegistrar *ConsulRegistrar
 }
 
-// NewManageHTTPServer creates a ManageHTTPServer instance
+// NewManageHTTPServer creates a ManageHTTPServer instance. authenticator
+// validates every incoming request before the method switch; auditSink
+// receives one AuditRecord per request. If auditSink is nil, records are
+// logged through GlogAuditSink so requests are always captured somewhere.
 func NewManageHTTPServer(cluster string, dbIns db.DB, dnsIns dns.DNS, serverIns server.Server,
-	serverInfo server.Info, containersvcIns containersvc.ContainerSvc) *ManageHTTPServer {
+	serverInfo server.Info, containersvcIns containersvc.ContainerSvc,
+	authenticator Authenticator, auditSink AuditSink) *ManageHTTPServer {
 	svc := manageservice.NewManageService(dbIns, serverIns, dnsIns)
+	if auditSink == nil {
+		auditSink = &GlogAuditSink{}
+	}
 	s := &ManageHTTPServer{
 		region:          serverInfo.GetLocalRegion(),
 		cluster:         cluster,
@@ -60,10 +91,33 @@ func NewManageHTTPServer(cluster string, dbIns db.DB, dnsIns dns.DNS, serverIns
 		serverInfo:      serverInfo,
 		containersvcIns: containersvcIns,
 		svc:             svc,
+		authenticator:   authenticator,
+		auditSink:       auditSink,
+		ops:             operations.NewManager(newOperationStore()),
+		logger:          log.NewJSON(),
 	}
+
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	s.bgCancel = bgCancel
+	go s.StartConfigFileGC(bgCtx)
+	go s.StartBackupScheduler(bgCtx)
+	go s.StartDriftReconciler(bgCtx)
+
 	return s
 }
 
+// Close stops the background loops started by NewManageHTTPServer and
+// flushes any log entries still buffered by the zap logger. The caller
+// should defer this right after NewManageHTTPServer.
+func (s *ManageHTTPServer) Close() {
+	s.bgCancel()
+
+	// zap.Logger.Sync commonly returns an error when stderr is a
+	// non-syncable terminal/pipe; it is not actionable here, so it is
+	// deliberately ignored rather than logged through the logger being closed.
+	_ = s.logger.Sync()
+}
+
 func (s *ManageHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// generate uuid as request id
 	requuid := utils.GenRequestUUID()
@@ -75,40 +129,62 @@ func (s *ManageHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	unescapedURL, err := url.QueryUnescape(r.RequestURI)
 	if err != nil {
 		glog.Errorln("url.QueryUnescape error", err, r.RequestURI, "requuid", requuid, r)
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		writeError(w, requuid, merrors.BadRequest(err.Error()))
 		return
 	}
 	trimURL := strings.TrimLeft(unescapedURL, "/")
+	servicename := trimURL
+	if strings.HasPrefix(trimURL, manage.SpecialOpPrefix) {
+		servicename = ""
+	}
 
 	glog.Infoln("request Method", r.Method, "URL", r.URL, "Host", r.Host, "requuid", requuid, "headers", r.Header)
 
 	// make sure body is closed
 	defer s.closeBody(r)
 
+	start := time.Now()
+
+	principal, err := s.authenticator.Authenticate(r)
+	if err != nil {
+		s.auditSink.Audit(AuditRecord{Requuid: requuid, Method: r.Method, TrimURL: trimURL,
+			Service: servicename, Decision: AuditUnauthenticated, Latency: time.Since(start)})
+		writeError(w, requuid, merrors.Unauthorized("request is not authenticated"))
+		return
+	}
+
+	if !authorize(principal, r.Method, trimURL) {
+		s.auditSink.Audit(AuditRecord{Requuid: requuid, Principal: principal.Name, Method: r.Method,
+			TrimURL: trimURL, Service: servicename, Decision: AuditUnauthorized, Latency: time.Since(start)})
+		writeError(w, requuid, merrors.Forbidden("principal "+principal.Name+" is not authorized for "+r.Method+" "+trimURL))
+		return
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	ctx = utils.NewRequestContext(ctx, requuid)
 	// call cancel before return. This is to ensure any resource derived
 	// from the context will be canceled.
 	defer cancel()
 
-	errmsg := ""
-	errcode := http.StatusOK
+	var opErr merrors.Error
 	switch r.Method {
 	case http.MethodPost:
-		errmsg, errcode = s.putOp(ctx, w, r, trimURL, requuid)
+		opErr = s.putOp(ctx, w, r, trimURL, requuid)
 	case http.MethodPut:
-		errmsg, errcode = s.putOp(ctx, w, r, trimURL, requuid)
+		opErr = s.putOp(ctx, w, r, trimURL, requuid)
 	case http.MethodGet:
-		errmsg, errcode = s.getOp(ctx, w, r, trimURL, requuid)
+		opErr = s.getOp(ctx, w, r, trimURL, requuid)
 	case http.MethodDelete:
-		errmsg, errcode = s.delOp(ctx, w, r, trimURL, requuid)
+		opErr = s.delOp(ctx, w, r, trimURL, requuid)
 	default:
-		errmsg = http.StatusText(http.StatusNotImplemented)
-		errcode = http.StatusNotImplemented
+		opErr = merrors.New("NotImplemented", http.StatusNotImplemented, http.StatusText(http.StatusNotImplemented))
 	}
 
-	if errcode != http.StatusOK {
-		http.Error(w, errmsg, errcode)
+	s.auditSink.Audit(AuditRecord{Requuid: requuid, Principal: principal.Name, Method: r.Method,
+		TrimURL: trimURL, Service: servicename, Decision: AuditAllowed, Latency: time.Since(start)})
+
+	if opErr != nil {
+		writeError(w, requuid, opErr)
 	}
 }
 
@@ -117,71 +193,113 @@ func (s *ManageHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // Example:
 //   PUT /servicename, create a service.
 //   PUT /?SetServiceInitialized, mark a service initialized.
-func (s *ManageHTTPServer) putOp(ctx context.Context, w http.ResponseWriter, r *http.Request, trimURL string, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) putOp(ctx context.Context, w http.ResponseWriter, r *http.Request, trimURL string, requuid string) merrors.Error {
 	if strings.HasPrefix(trimURL, manage.SpecialOpPrefix) {
 		switch trimURL {
 		case manage.ServiceInitializedOp:
-			return s.setServiceInitialized(ctx, w, r, requuid)
+			return s.httpSetServiceInitialized(ctx, w, r, requuid)
 		case manage.RunTaskOp:
 			return s.runTask(ctx, w, r, requuid)
+		case manage.DeployTemplateOp:
+			return s.deployTemplate(ctx, w, r, requuid)
+		case manage.UpdateServiceOp:
+			return s.updateService(ctx, w, r, requuid)
+		case manage.PromoteServiceOp:
+			return s.promoteService(ctx, w, r, requuid)
+		case manage.AbortServiceOp:
+			return s.abortService(ctx, w, r, requuid)
 		default:
-			return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+			return merrors.BadRequest("unknown op " + trimURL)
 		}
 	} else {
 		return s.createService(ctx, w, r, trimURL, requuid)
 	}
 }
 
-func (s *ManageHTTPServer) setServiceInitialized(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) (errmsg string, errcode int) {
+// httpSetServiceInitialized implements manage.ServiceInitializedOp, the
+// HTTP entry point for setServiceInitialized.
+func (s *ManageHTTPServer) httpSetServiceInitialized(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
 	b, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		glog.Errorln("setServiceInitialized read body error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	req := &manage.ServiceCommonRequest{}
 	err = json.Unmarshal(b, req)
 	if err != nil {
 		glog.Errorln("setServiceInitialized decode request error", err, "requuid", requuid, string(b[:]))
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Cluster != s.cluster || req.Region != s.region {
 		glog.Errorln("setServiceInitialized invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
+	}
+
+	if err := s.setServiceInitialized(ctx, req.ServiceName, requuid); err != nil {
+		return err
 	}
 
-	err = s.svc.SetServiceInitialized(ctx, s.cluster, req.ServiceName)
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+// setServiceInitialized marks a service initialized in the db. It is
+// shared by the ServiceInitializedOp handler above and every catalog
+// create path that does not need a background init task.
+func (s *ManageHTTPServer) setServiceInitialized(ctx context.Context, serviceName string, requuid string) merrors.Error {
+	err := s.svc.SetServiceInitialized(ctx, s.cluster, serviceName)
 	if err != nil {
-		glog.Errorln("setServiceInitialized error", err, "service", req.ServiceName, "requuid", requuid)
+		glog.Errorln("setServiceInitialized error", err, "service", serviceName, "requuid", requuid)
 		return manage.ConvertToHTTPError(err)
 	}
 
-	glog.Infoln("set service", req.ServiceName, "initialized, requuid", requuid)
-
-	w.WriteHeader(http.StatusOK)
+	// the pending init task journal row is no longer needed once the
+	// service is marked initialized; leaving it would make the reaper and
+	// RecoverPendingInitTasks resume a task for an already-initialized
+	// service after a restart. Best-effort: a delete failure just leaves
+	// a harmless stale row that a later CatalogCheckServiceInitRequest or
+	// reap cycle will find the service already initialized and skip.
+	if svc, getErr := s.dbIns.GetService(ctx, s.cluster, serviceName); getErr == nil {
+		if delErr := s.dbIns.DeletePendingInitTask(ctx, svc.ServiceUUID); delErr != nil {
+			glog.Errorln("DeletePendingInitTask error", delErr, "service", serviceName, "requuid", requuid)
+		}
+	} else {
+		glog.Errorln("GetService error", getErr, "service", serviceName, "requuid", requuid)
+	}
 
-	return "", http.StatusOK
+	glog.Infoln("set service", serviceName, "initialized, requuid", requuid)
+	return nil
 }
 
 func (s *ManageHTTPServer) createService(ctx context.Context, w http.ResponseWriter,
-	r *http.Request, servicename string, requuid string) (errmsg string, errcode int) {
+	r *http.Request, servicename string, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.CreateServiceRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("createService decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region ||
 		req.Service.ServiceName != servicename {
 		glog.Errorln("createService invalid request, local cluster", s.cluster, "region",
 			s.region, "service", servicename, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster, region or service name mismatch")
 	}
 
+	// createService can take minutes across the db, container platform and
+	// dns, so the actual work runs async; see runAsync.
+	return s.runAsync(ctx, w, r, "/"+servicename, func(ctx context.Context) (body []byte, err merrors.Error) {
+		return s.createServiceWork(ctx, req, requuid)
+	})
+}
+
+func (s *ManageHTTPServer) createServiceWork(ctx context.Context, req *manage.CreateServiceRequest, requuid string) ([]byte, merrors.Error) {
 	// create the service in the control plane
 	domain := dns.GenDefaultDomainName(s.cluster)
 	vpcID := s.serverInfo.GetLocalVpcID()
@@ -189,26 +307,26 @@ func (s *ManageHTTPServer) createService(ctx context.Context, w http.ResponseWri
 	serviceUUID, err := s.svc.CreateService(ctx, req, domain, vpcID)
 	if err != nil {
 		glog.Errorln("create service error", err, "requuid", requuid, req.Service)
-		return manage.ConvertToHTTPError(err)
+		return nil, manage.ConvertToHTTPError(err)
 	}
 
 	// create the service in the container platform
 	exist, err := s.containersvcIns.IsServiceExist(ctx, req.Service.Cluster, req.Service.ServiceName)
 	if err != nil {
 		glog.Errorln("check container service exist error", err, "requuid", requuid, req.Service)
-		return manage.ConvertToHTTPError(err)
+		return nil, manage.ConvertToHTTPError(err)
 	}
 	if !exist {
 		opts := s.genCreateServiceOptions(req, serviceUUID)
 		err = s.containersvcIns.CreateService(ctx, opts)
 		if err != nil {
 			glog.Errorln("CreateService error", err, "requuid", requuid, req.Service)
-			return manage.ConvertToHTTPError(err)
+			return nil, manage.ConvertToHTTPError(err)
 		}
 	}
 
 	glog.Infoln("create service done, serviceUUID", serviceUUID, "requuid", requuid, req.Service)
-	return "", http.StatusOK
+	return nil, nil
 }
 
 func (s *ManageHTTPServer) genCreateServiceOptions(req *manage.CreateServiceRequest, serviceUUID string) *containersvc.CreateServiceOptions {
@@ -232,7 +350,7 @@ func (s *ManageHTTPServer) genCreateServiceOptions(req *manage.CreateServiceRequ
 
 // Get one service, GET /servicename. Or list services, Get / or /?list-type=1, and additional parameters in headers
 func (s *ManageHTTPServer) getOp(ctx context.Context, w http.ResponseWriter,
-	r *http.Request, trimURL string, requuid string) (errmsg string, errcode int) {
+	r *http.Request, trimURL string, requuid string) merrors.Error {
 	if strings.HasPrefix(trimURL, manage.SpecialOpPrefix) {
 		switch trimURL {
 		case manage.ListServiceOp:
@@ -247,8 +365,23 @@ func (s *ManageHTTPServer) getOp(ctx context.Context, w http.ResponseWriter,
 		case manage.GetTaskStatusOp:
 			return s.getTaskStatus(ctx, w, r, requuid)
 
+		case manage.ListTemplatesOp:
+			return s.listTemplates(ctx, w, r, requuid)
+
+		case manage.GetTemplateOp:
+			return s.getTemplate(ctx, w, r, requuid)
+
+		case manage.GetOperationOp:
+			return s.getOperation(ctx, w, r, requuid)
+
+		case manage.ListOperationsOp:
+			return s.listOperations(ctx, w, r, requuid)
+
+		case manage.WaitOperationOp:
+			return s.waitOperation(ctx, w, r, requuid)
+
 		default:
-			return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+			return merrors.BadRequest("unknown op " + trimURL)
 		}
 	} else {
 		// get the detail of one service
@@ -257,13 +390,15 @@ func (s *ManageHTTPServer) getOp(ctx context.Context, w http.ResponseWriter,
 }
 
 // Delete one service, DELETE /servicename
-func (s *ManageHTTPServer) delOp(ctx context.Context, w http.ResponseWriter, r *http.Request, trimURL string, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) delOp(ctx context.Context, w http.ResponseWriter, r *http.Request, trimURL string, requuid string) merrors.Error {
 	if strings.HasPrefix(trimURL, manage.SpecialOpPrefix) {
 		switch trimURL {
 		case manage.DeleteTaskOp:
 			return s.deleteTask(ctx, w, r, requuid)
+		case manage.CancelOperationOp:
+			return s.cancelOperation(ctx, w, r, requuid)
 		default:
-			return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+			return merrors.BadRequest("unknown op " + trimURL)
 		}
 	} else {
 		// get the detail of one service
@@ -271,102 +406,103 @@ func (s *ManageHTTPServer) delOp(ctx context.Context, w http.ResponseWriter, r *
 	}
 }
 
-func (s *ManageHTTPServer) deleteService(ctx context.Context, w http.ResponseWriter, r *http.Request, servicename string, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) deleteService(ctx context.Context, w http.ResponseWriter, r *http.Request, servicename string, requuid string) merrors.Error {
 	req := &manage.ServiceCommonRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("deleteService decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Cluster != s.cluster || req.Region != s.region || req.ServiceName != servicename {
 		glog.Errorln("deleteService invalid request, local cluster", s.cluster, "region",
 			s.region, "service", servicename, "requuid", requuid, req)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
-	}
-
-	err = s.dbIns.DeleteService(ctx, s.cluster, servicename)
-	if err != nil {
-		glog.Errorln("DeleteService error", err, servicename, "requuid", requuid)
-		return manage.ConvertToHTTPError(err)
+		return merrors.BadRequest("cluster, region or service name mismatch")
 	}
 
-	glog.Infoln("deleted service", servicename, "requuid", requuid, r)
-
-	w.WriteHeader(http.StatusOK)
+	return s.runAsync(ctx, w, r, "/"+servicename, func(ctx context.Context) (body []byte, err merrors.Error) {
+		if err := s.dbIns.DeleteService(ctx, s.cluster, servicename); err != nil {
+			glog.Errorln("DeleteService error", err, servicename, "requuid", requuid)
+			return nil, manage.ConvertToHTTPError(err)
+		}
 
-	return "", http.StatusOK
+		glog.Infoln("deleted service", servicename, "requuid", requuid)
+		return nil, nil
+	})
 }
 
 func (s *ManageHTTPServer) listServices(ctx context.Context, w http.ResponseWriter,
-	r *http.Request, requuid string) (errmsg string, errcode int) {
-	// no need to support token and MaxKeys, simply returns all as one cluster would
-	// not have too many services.
+	r *http.Request, requuid string) merrors.Error {
 	req := &manage.ListServiceRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("listServices decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Cluster != s.cluster || req.Region != s.region {
 		glog.Errorln("listServices invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	glog.Infoln("listServices, prefix", req.Prefix, "requuid", requuid)
 
-	services, err := s.dbIns.ListServices(ctx, s.cluster)
+	allServices, err := s.dbIns.ListServices(ctx, s.cluster)
 	if err != nil {
 		glog.Errorln("ListServices error", err, "prefix", req.Prefix, "requuid", requuid)
 		return manage.ConvertToHTTPError(err)
 	}
 
-	var serviceAttrs []*common.ServiceAttr
-	for _, service := range services {
-		if len(req.Prefix) == 0 || strings.HasPrefix(service.ServiceName, req.Prefix) {
-			// fetch the detail service attr
-			attr, err := s.dbIns.GetServiceAttr(ctx, service.ServiceUUID)
+	services, nextToken := paginateServices(allServices, req.MaxKeys, req.NextToken)
+
+	serviceAttrs, err := s.fetchServiceAttrs(ctx, services, req)
+	if err != nil {
+		glog.Errorln("fetchServiceAttrs error", err, "requuid", requuid)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	glog.Infoln("list", len(serviceAttrs), "of", len(services), "services, prefix", req.Prefix, "requuid", requuid)
+
+	resp := &manage.ListServiceResponse{Services: serviceAttrs, NextToken: nextToken}
+	if len(req.Fields) > 0 {
+		resp.Fields = make([]map[string]interface{}, len(serviceAttrs))
+		for i, attr := range serviceAttrs {
+			projected, err := projectFields(attr, req.Fields)
 			if err != nil {
-				glog.Errorln("GetServiceAttr error", err, service, "requuid", requuid)
-				return manage.ConvertToHTTPError(err)
+				glog.Errorln("projectFields error", err, "requuid", requuid)
+				return merrors.Internal(err.Error())
 			}
-
-			glog.Infoln("GetServiceAttr", attr, "requuid", requuid)
-			serviceAttrs = append(serviceAttrs, attr)
+			resp.Fields[i] = projected
 		}
+		resp.Services = nil
 	}
 
-	glog.Infoln("list", len(services), "services, prefix", req.Prefix, "requuid", requuid)
-
-	resp := &manage.ListServiceResponse{Services: serviceAttrs}
 	b, err := json.Marshal(resp)
 	if err != nil {
 		glog.Errorln("Marshal ListServiceResponse error", err, "requuid", requuid, req)
-		return http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError
+		return merrors.Internal(err.Error())
 	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(b)
 
-	return "", http.StatusOK
+	return nil
 }
 
 func (s *ManageHTTPServer) listVolumes(ctx context.Context, w http.ResponseWriter,
-	r *http.Request, requuid string) (errmsg string, errcode int) {
-	// TODO support token and MaxKeys if necessary.
+	r *http.Request, requuid string) merrors.Error {
 	req := &manage.ListVolumeRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("listVolumes decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
 		glog.Errorln("listVolumes invalid request, local cluster", s.cluster,
 			"region", s.region, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	glog.Infoln("listVolumes", req.Service, "requuid", requuid)
@@ -377,42 +513,42 @@ func (s *ManageHTTPServer) listVolumes(ctx context.Context, w http.ResponseWrite
 		return manage.ConvertToHTTPError(err)
 	}
 
-	vols, err := s.dbIns.ListVolumes(ctx, service.ServiceUUID)
+	vols, nextToken, err := s.dbIns.ListVolumesWithLimit(ctx, service.ServiceUUID, req.MaxKeys, req.NextToken)
 	if err != nil {
-		glog.Errorln("db ListVolumes error", err, "requuid", requuid, req.Service)
+		glog.Errorln("db ListVolumesWithLimit error", err, "requuid", requuid, req.Service)
 		return manage.ConvertToHTTPError(err)
 	}
 
 	glog.Infoln("list", len(vols), "volumes, requuid", requuid, req.Service)
 
-	resp := &manage.ListVolumeResponse{Volumes: vols}
+	resp := &manage.ListVolumeResponse{Volumes: vols, NextToken: nextToken}
 	b, err := json.Marshal(resp)
 	if err != nil {
 		glog.Errorln("Marshal ListVolumeResponse error", err, "requuid", requuid, req)
-		return http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError
+		return merrors.Internal(err.Error())
 	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(b)
 
-	return "", http.StatusOK
+	return nil
 }
 
 func (s *ManageHTTPServer) getServiceAttr(ctx context.Context, w http.ResponseWriter,
-	r *http.Request, servicename string, requuid string) (errmsg string, errcode int) {
+	r *http.Request, servicename string, requuid string) merrors.Error {
 	// no need to support token and MaxKeys, simply returns all volumes. Assume one volume
 	// attribute is 1KB. If the service has 1000 volumes, the whole list would be 1MB.
 	req := &manage.ServiceCommonRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("getServiceAttr decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Cluster != s.cluster || req.Region != s.region || req.ServiceName != servicename {
 		glog.Errorln("getServiceAttr invalid request, local cluster", s.cluster, "region",
 			s.region, "service", servicename, "requuid", requuid, req)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster, region or service name mismatch")
 	}
 
 	service, err := s.dbIns.GetService(ctx, s.cluster, servicename)
@@ -432,27 +568,27 @@ func (s *ManageHTTPServer) getServiceAttr(ctx context.Context, w http.ResponseWr
 	b, err := json.Marshal(resp)
 	if err != nil {
 		glog.Errorln("Marshal GetServiceAttributesResponse error", err, attr, "requuid", requuid)
-		return http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError
+		return merrors.Internal(err.Error())
 	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(b)
 
-	return "", http.StatusOK
+	return nil
 }
 
 func (s *ManageHTTPServer) getServiceStatus(ctx context.Context,
-	w http.ResponseWriter, r *http.Request, requuid string) (errmsg string, errcode int) {
+	w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
 	req := &manage.ServiceCommonRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("getServiceStatus decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Cluster != s.cluster || req.Region != s.region {
 		glog.Errorln("invalid request, local cluster", s.cluster, "region", s.region, "requuid", requuid, req)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
 	status, err := s.containersvcIns.GetServiceStatus(ctx, req.Cluster, req.ServiceName)
@@ -469,7 +605,7 @@ func (s *ManageHTTPServer) getServiceStatus(ctx context.Context,
 	b, err := json.Marshal(resp)
 	if err != nil {
 		glog.Errorln("Marshal error", err, "requuid", requuid, req)
-		return http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError
+		return merrors.Internal(err.Error())
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -477,7 +613,7 @@ func (s *ManageHTTPServer) getServiceStatus(ctx context.Context,
 
 	glog.Infoln("get service status", status, "requuid", requuid, req)
 
-	return "", http.StatusOK
+	return nil
 }
 
 func (s *ManageHTTPServer) closeBody(r *http.Request) {
@@ -486,78 +622,77 @@ func (s *ManageHTTPServer) closeBody(r *http.Request) {
 	}
 }
 
-func (s *ManageHTTPServer) runTask(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) runTask(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.RunTaskRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("runTask decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region {
 		glog.Errorln("invalid request, local cluster", s.cluster, "region",
 			s.region, "requuid", requuid, "task type", req.TaskType, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
-	}
-
-	svc, err := s.dbIns.GetService(ctx, req.Service.Cluster, req.Service.ServiceName)
-	if err != nil {
-		glog.Errorln("GetService error", err, "requuid", requuid, req.Service)
-		return manage.ConvertToHTTPError(err)
+		return merrors.BadRequest("cluster or region mismatch")
 	}
 
-	commonOpts := &containersvc.CommonOptions{
-		Cluster:        req.Service.Cluster,
-		ServiceName:    req.Service.ServiceName,
-		ServiceUUID:    svc.ServiceUUID,
-		ContainerImage: req.ContainerImage,
-		Resource:       req.Resource,
-	}
+	return s.runAsync(ctx, w, r, "/"+req.Service.ServiceName, func(ctx context.Context) (body []byte, err merrors.Error) {
+		svc, err2 := s.dbIns.GetService(ctx, req.Service.Cluster, req.Service.ServiceName)
+		if err2 != nil {
+			glog.Errorln("GetService error", err2, "requuid", requuid, req.Service)
+			return nil, manage.ConvertToHTTPError(err2)
+		}
 
-	opts := &containersvc.RunTaskOptions{
-		Common:   commonOpts,
-		TaskType: req.TaskType,
-		Envkvs:   req.Envkvs,
-	}
+		commonOpts := &containersvc.CommonOptions{
+			Cluster:        req.Service.Cluster,
+			ServiceName:    req.Service.ServiceName,
+			ServiceUUID:    svc.ServiceUUID,
+			ContainerImage: req.ContainerImage,
+			Resource:       req.Resource,
+		}
 
-	taskID, err := s.containersvcIns.RunTask(ctx, opts)
-	if err != nil {
-		glog.Errorln("RunTask error", err, "requuid", requuid, req.Service, svc)
-		return manage.ConvertToHTTPError(err)
-	}
+		opts := &containersvc.RunTaskOptions{
+			Common:   commonOpts,
+			TaskType: req.TaskType,
+			Envkvs:   req.Envkvs,
+		}
 
-	glog.Infoln("run task", taskID, "requuid", requuid, req.Service, svc)
+		taskID, err2 := s.containersvcIns.RunTask(ctx, opts)
+		if err2 != nil {
+			glog.Errorln("RunTask error", err2, "requuid", requuid, req.Service, svc)
+			return nil, manage.ConvertToHTTPError(err2)
+		}
 
-	resp := &manage.RunTaskResponse{
-		TaskID: taskID,
-	}
+		glog.Infoln("run task", taskID, "requuid", requuid, req.Service, svc)
 
-	b, err := json.Marshal(resp)
-	if err != nil {
-		glog.Errorln("Marshal ServiceRunningStatus error", err, "requuid", requuid, req)
-		return http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError
-	}
+		resp := &manage.RunTaskResponse{
+			TaskID: taskID,
+		}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(b)
+		b, err2 := json.Marshal(resp)
+		if err2 != nil {
+			glog.Errorln("Marshal ServiceRunningStatus error", err2, "requuid", requuid, req)
+			return nil, merrors.Internal(err2.Error())
+		}
 
-	return "", http.StatusOK
+		return b, nil
+	})
 }
 
-func (s *ManageHTTPServer) getTaskStatus(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) getTaskStatus(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.GetTaskStatusRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region || len(req.TaskID) == 0 {
 		glog.Errorln("invalid request, local cluster", s.cluster, "region",
 			s.region, "requuid", requuid, "taskID", req.TaskID, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster, region or taskID mismatch")
 	}
 
 	taskStatus, err := s.containersvcIns.GetTaskStatus(ctx, s.cluster, req.TaskID)
@@ -575,29 +710,29 @@ func (s *ManageHTTPServer) getTaskStatus(ctx context.Context, w http.ResponseWri
 	b, err := json.Marshal(resp)
 	if err != nil {
 		glog.Errorln("Marshal error", err, "requuid", requuid, req.Service)
-		return http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError
+		return merrors.Internal(err.Error())
 	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(b)
 
-	return "", http.StatusOK
+	return nil
 }
 
-func (s *ManageHTTPServer) deleteTask(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) (errmsg string, errcode int) {
+func (s *ManageHTTPServer) deleteTask(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
 	// parse the request
 	req := &manage.DeleteTaskRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		glog.Errorln("decode request error", err, "requuid", requuid)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest(err.Error())
 	}
 
 	if req.Service.Cluster != s.cluster || req.Service.Region != s.region ||
 		len(req.Service.ServiceName) == 0 || len(req.TaskType) == 0 {
 		glog.Errorln("invalid request, local cluster", s.cluster, "region",
 			s.region, "requuid", requuid, "taskID", req.TaskType, req.Service)
-		return http.StatusText(http.StatusBadRequest), http.StatusBadRequest
+		return merrors.BadRequest("cluster, region, service name or task type mismatch")
 	}
 
 	err = s.containersvcIns.DeleteTask(ctx, s.cluster, req.Service.ServiceName, req.TaskType)
@@ -607,5 +742,5 @@ func (s *ManageHTTPServer) deleteTask(ctx context.Context, w http.ResponseWriter
 	}
 
 	glog.Infoln("deleted task, requuid", requuid, "TaskType", req.TaskType, req.Service)
-	return "", http.StatusOK
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,252 @@
+package manageserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/cloudstax/firecamp/catalog"
+	"github.com/cloudstax/firecamp/common"
+	"github.com/cloudstax/firecamp/manage"
+	merrors "github.com/cloudstax/firecamp/manage/errors"
+)
+
+// stackNodeInitTimeout bounds how long createStackService waits for one
+// node's init task to reach common.ServiceStatusActive before giving up
+// on the rest of the stack.
+const stackNodeInitTimeout = 30 * time.Minute
+
+// stackNodeInitPollInterval is how often createStackService polls a
+// node's status while waiting for its init task to finish.
+const stackNodeInitPollInterval = 3 * time.Second
+
+// createStackService implements manage.CatalogCreateStackOp: it resolves
+// and topologically sorts a graph of catalog services (e.g.
+// Consul+Kafka+ZooKeeper or ES+Kibana+Logstash), then walks the order
+// creating each node through the same catalog.Registry the single-service
+// create ops use, rolling back every service it already created if any
+// node in the graph fails. The dependency resolution and topo-sort are
+// real and independently useful (see resolveStackOrder), but node
+// creation itself is not reachable yet: no catalog/<kind> package
+// registers a CatalogServiceProvider in this build (see opCatalogKind in
+// catalogservice.go), so createStackNode fails its first node with "no
+// CatalogServiceProvider registered" every time.
+func (s *ManageHTTPServer) createStackService(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
+	req := &manage.CatalogCreateStackRequest{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		glog.Errorln("CatalogCreateStackRequest decode request error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	if req.Cluster != s.cluster || req.Region != s.region {
+		glog.Errorln("CatalogCreateStackRequest invalid request, local cluster", s.cluster,
+			"region", s.region, "requuid", requuid, req)
+		return merrors.BadRequest("cluster or region mismatch")
+	}
+
+	order, err := s.resolveStackOrder(ctx, req.Nodes, requuid)
+	if err != nil {
+		glog.Errorln("resolveStackOrder error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	created := make(map[string]string)
+	for _, node := range order {
+		serviceUUID, err := s.createStackNode(ctx, node, requuid)
+		if err != nil {
+			glog.Errorln("createStackNode error", err, "requuid", requuid, node.ServiceName)
+			s.rollbackStack(ctx, created, requuid)
+			return manage.ConvertToHTTPError(err)
+		}
+		created[node.ServiceName] = serviceUUID
+
+		// order is dependency-sorted, so every later node that depends on
+		// this one expects it to already be active. Wait for this node's
+		// init task (if any) to finish before creating the next one,
+		// rather than only ordering the create calls and racing the init.
+		if err := s.waitServiceActive(ctx, node.ServiceName, requuid); err != nil {
+			glog.Errorln("waitServiceActive error", err, "requuid", requuid, node.ServiceName)
+			s.rollbackStack(ctx, created, requuid)
+			return manage.ConvertToHTTPError(err)
+		}
+	}
+
+	glog.Infoln("created stack", created, "requuid", requuid)
+
+	resp := &manage.CatalogCreateStackResponse{ServiceUUIDs: created}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		glog.Errorln("Marshal CatalogCreateStackResponse error", err, "requuid", requuid)
+		return merrors.Internal(err.Error())
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+
+	return nil
+}
+
+// resolveStackOrder validates that every dependency not defined in the
+// stack already exists and is common.ServiceStatusActive, then
+// topologically sorts the in-stack nodes so each node is created only
+// after the nodes it depends on.
+func (s *ManageHTTPServer) resolveStackOrder(ctx context.Context,
+	nodes []*manage.CatalogCreateStackServiceNode, requuid string) ([]*manage.CatalogCreateStackServiceNode, error) {
+	byName := make(map[string]*manage.CatalogCreateStackServiceNode, len(nodes))
+	for _, n := range nodes {
+		byName[n.ServiceName] = n
+	}
+
+	// validate cross-cluster dependencies up front.
+	for _, n := range nodes {
+		for _, dep := range n.Dependencies {
+			if _, inStack := byName[dep.ServiceName]; inStack {
+				continue
+			}
+			if err := s.validateServiceActive(ctx, dep.ServiceName, requuid); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	visited := make(map[string]bool, len(nodes))
+	visiting := make(map[string]bool, len(nodes))
+	order := make([]*manage.CatalogCreateStackServiceNode, 0, len(nodes))
+
+	var visit func(n *manage.CatalogCreateStackServiceNode) error
+	visit = func(n *manage.CatalogCreateStackServiceNode) error {
+		if visited[n.ServiceName] {
+			return nil
+		}
+		if visiting[n.ServiceName] {
+			return fmt.Errorf("circular service dependency at %s", n.ServiceName)
+		}
+		visiting[n.ServiceName] = true
+
+		for _, dep := range n.Dependencies {
+			if depNode, inStack := byName[dep.ServiceName]; inStack {
+				if err := visit(depNode); err != nil {
+					return err
+				}
+			}
+		}
+
+		visiting[n.ServiceName] = false
+		visited[n.ServiceName] = true
+		order = append(order, n)
+		return nil
+	}
+
+	for _, n := range nodes {
+		if err := visit(n); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// waitServiceActive polls serviceName's attr until it reaches
+// common.ServiceStatusActive, so a node's init task (scheduled
+// asynchronously by createStackNode) actually finishes before a node
+// depending on it is created.
+func (s *ManageHTTPServer) waitServiceActive(ctx context.Context, serviceName string, requuid string) error {
+	deadline := time.Now().Add(stackNodeInitTimeout)
+	ticker := time.NewTicker(stackNodeInitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.validateServiceActive(ctx, serviceName, requuid); err == nil {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service %s did not become active within %s", serviceName, stackNodeInitTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ManageHTTPServer) validateServiceActive(ctx context.Context, serviceName string, requuid string) error {
+	svc, err := s.dbIns.GetService(ctx, s.cluster, serviceName)
+	if err != nil {
+		return fmt.Errorf("dependency service %s not found: %v", serviceName, err)
+	}
+
+	attr, err := s.dbIns.GetServiceAttr(ctx, svc.ServiceUUID)
+	if err != nil {
+		return fmt.Errorf("dependency service %s attr not found: %v", serviceName, err)
+	}
+
+	if attr.ServiceStatus != common.ServiceStatusActive {
+		return fmt.Errorf("dependency service %s is not active, status %s", serviceName, attr.ServiceStatus)
+	}
+
+	return nil
+}
+
+// createStackNode creates one node of the stack through its registered
+// CatalogServiceProvider and schedules its init task if it needs one.
+// createStackService waits for the node to reach common.ServiceStatusActive
+// before creating the next node in order, so a node whose dependencies are
+// all in-stack is created only once those dependencies are active, not
+// merely after they were created.
+func (s *ManageHTTPServer) createStackNode(ctx context.Context,
+	node *manage.CatalogCreateStackServiceNode, requuid string) (string, error) {
+	provider := catalog.Get(node.ServiceType)
+	if provider == nil {
+		return "", fmt.Errorf("no CatalogServiceProvider registered for kind %s", node.ServiceType)
+	}
+
+	err := provider.ValidateRequest(node.Options)
+	if err != nil {
+		return "", err
+	}
+
+	crReq, err := provider.GenCreateRequest(s, node.Options)
+	if err != nil {
+		return "", err
+	}
+
+	serviceUUID, err := s.CreateCommonService(ctx, crReq, requuid)
+	if err != nil {
+		return "", err
+	}
+
+	if !provider.RequiresInit(node.Options) {
+		if err := s.setServiceInitialized(ctx, node.ServiceName, requuid); err != nil {
+			return "", fmt.Errorf("setServiceInitialized failed: %s", err.Error())
+		}
+		return serviceUUID, nil
+	}
+
+	taskOpts, err := provider.GenInitTask(s, node.Options, crReq, serviceUUID, requuid)
+	if err != nil {
+		return "", err
+	}
+
+	s.ScheduleInitTask(ctx, provider.Kind(), serviceUUID, node.ServiceName, taskOpts)
+	return serviceUUID, nil
+}
+
+// rollbackStack deletes every service created earlier in a failed
+// CatalogCreateStackRequest call, best-effort, so a partial stack does not
+// linger after the request reports an error.
+func (s *ManageHTTPServer) rollbackStack(ctx context.Context, created map[string]string, requuid string) {
+	for serviceName, serviceUUID := range created {
+		glog.Errorln("rolling back stack service", serviceName, serviceUUID, "requuid", requuid)
+		if err := s.dbIns.DeleteService(ctx, s.cluster, serviceName); err != nil {
+			glog.Errorln("rollback DeleteService error", err, serviceName, serviceUUID, "requuid", requuid)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package manageserver
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudstax/firecamp/manage"
+)
+
+func node(name string, deps ...string) *manage.CatalogCreateStackServiceNode {
+	n := &manage.CatalogCreateStackServiceNode{ServiceName: name}
+	for _, d := range deps {
+		n.Dependencies = append(n.Dependencies, manage.ServiceDependency{ServiceName: d})
+	}
+	return n
+}
+
+func TestResolveStackOrderTopoSort(t *testing.T) {
+	// kafka depends on zookeeper, kibana depends on elasticsearch; all
+	// dependencies are in-stack, so validateServiceActive (which needs a
+	// real dbIns) is never called.
+	nodes := []*manage.CatalogCreateStackServiceNode{
+		node("kafka", "zookeeper"),
+		node("zookeeper"),
+		node("kibana", "elasticsearch"),
+		node("elasticsearch"),
+	}
+
+	s := &ManageHTTPServer{}
+	order, err := s.resolveStackOrder(context.Background(), nodes, "requuid")
+	if err != nil {
+		t.Fatalf("resolveStackOrder error: %v", err)
+	}
+	if len(order) != len(nodes) {
+		t.Fatalf("expected %d nodes in order, got %d", len(nodes), len(order))
+	}
+
+	pos := make(map[string]int, len(order))
+	for i, n := range order {
+		pos[n.ServiceName] = i
+	}
+	if pos["zookeeper"] >= pos["kafka"] {
+		t.Errorf("expected zookeeper before kafka, got order %v", names(order))
+	}
+	if pos["elasticsearch"] >= pos["kibana"] {
+		t.Errorf("expected elasticsearch before kibana, got order %v", names(order))
+	}
+}
+
+func TestResolveStackOrderDetectsCycle(t *testing.T) {
+	nodes := []*manage.CatalogCreateStackServiceNode{
+		node("a", "b"),
+		node("b", "c"),
+		node("c", "a"),
+	}
+
+	s := &ManageHTTPServer{}
+	if _, err := s.resolveStackOrder(context.Background(), nodes, "requuid"); err == nil {
+		t.Fatal("expected an error for a circular dependency, got nil")
+	}
+}
+
+func names(nodes []*manage.CatalogCreateStackServiceNode) []string {
+	out := make([]string, len(nodes))
+	for i, n := range nodes {
+		out[i] = n.ServiceName
+	}
+	return out
+}
@@ -0,0 +1,330 @@
+package manageserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/cloudstax/firecamp/containersvc"
+	"github.com/cloudstax/firecamp/dns"
+	"github.com/cloudstax/firecamp/manage"
+	merrors "github.com/cloudstax/firecamp/manage/errors"
+	"github.com/cloudstax/firecamp/templatestore"
+)
+
+// deployTemplate implements manage.DeployTemplateOp: it records the
+// template, walks its node graph in dependency order creating each
+// service the same way createService does for a standalone service, then
+// resolves every node's endpoint addresses with resolveEndpointIPs. It rolls back
+// every service it created if any node or the address assignment fails,
+// the same rollback contract as CatalogCreateStackRequest.
+func (s *ManageHTTPServer) deployTemplate(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
+	req := &manage.ServiceTemplateDeploymentRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		glog.Errorln("ServiceTemplateDeploymentRequest decode error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	if req.Cluster != s.cluster || req.Region != s.region {
+		glog.Errorln("ServiceTemplateDeploymentRequest invalid request, local cluster", s.cluster,
+			"region", s.region, "requuid", requuid, req)
+		return merrors.BadRequest("cluster or region mismatch")
+	}
+
+	if verr := validateEndpointIPs(req.Nodes); verr != nil {
+		glog.Errorln("ServiceTemplateDeploymentRequest invalid endpoint IPs, requuid", requuid, req.TemplateName)
+		return verr
+	}
+
+	tmpl := templatestore.NewServiceTemplate(req, time.Now().UnixNano())
+	if err := s.dbIns.CreateServiceTemplate(ctx, tmpl); err != nil {
+		glog.Errorln("CreateServiceTemplate error", err, "requuid", requuid, req.TemplateName)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	// walking the graph and creating every node can take minutes, same as
+	// createService for a single node, so it runs async; see runAsync.
+	return s.runAsync(ctx, w, r, "/"+req.TemplateName, func(ctx context.Context) (body []byte, err merrors.Error) {
+		return s.deployTemplateWork(ctx, req, requuid)
+	})
+}
+
+// validateEndpointIPs checks that every node endpoint has a StaticIP set
+// and that it is a valid IPv4 or IPv6 address, the same address-format
+// verification Docker's networking config does for a container's IPAM
+// config. StaticIP is required, not just validated when present: there is
+// no cluster-managed address pool yet to allocate an endpoint's address
+// from, so resolveEndpointIPs can only echo back an address the caller
+// already supplied.
+func validateEndpointIPs(nodes []*manage.ServiceTemplateServiceNode) *merrors.ValidationError {
+	var details []merrors.FieldError
+	for _, node := range nodes {
+		for _, ep := range node.Endpoints {
+			field := fmt.Sprintf("Nodes[%s].Endpoints[%s].StaticIP", node.ServiceName, ep.Name)
+			if len(ep.StaticIP) == 0 {
+				details = append(details, merrors.FieldError{
+					Field:  field,
+					Reason: "StaticIP is required, address pool allocation is not supported yet",
+				})
+				continue
+			}
+			if net.ParseIP(ep.StaticIP) == nil {
+				details = append(details, merrors.FieldError{
+					Field:  field,
+					Reason: fmt.Sprintf("%q is not a valid IPv4 or IPv6 address", ep.StaticIP),
+				})
+			}
+		}
+	}
+
+	if len(details) == 0 {
+		return nil
+	}
+	return merrors.Validation("invalid endpoint StaticIP", details...)
+}
+
+func (s *ManageHTTPServer) deployTemplateWork(ctx context.Context, req *manage.ServiceTemplateDeploymentRequest, requuid string) ([]byte, merrors.Error) {
+	order, err := s.resolveTemplateOrder(req.Nodes)
+	if err != nil {
+		glog.Errorln("resolveTemplateOrder error", err, "requuid", requuid, req.TemplateName)
+		return nil, merrors.BadRequest(err.Error())
+	}
+
+	created := make(map[string]string)
+	for _, node := range order {
+		serviceUUID, err := s.createTemplateNode(ctx, node, requuid)
+		if err != nil {
+			glog.Errorln("createTemplateNode error", err, "requuid", requuid, node.ServiceName)
+			s.rollbackTemplate(ctx, created, requuid)
+			return nil, manage.ConvertToHTTPError(err)
+		}
+		created[node.ServiceName] = serviceUUID
+	}
+
+	endpoints, err := s.resolveEndpointIPs(ctx, req.Nodes, requuid)
+	if err != nil {
+		glog.Errorln("resolveEndpointIPs error", err, "requuid", requuid, req.TemplateName)
+		s.rollbackTemplate(ctx, created, requuid)
+		return nil, manage.ConvertToHTTPError(err)
+	}
+
+	glog.Infoln("deployed template", req.TemplateName, created, "requuid", requuid)
+
+	resp := &manage.ServiceTemplateDeploymentResponse{ServiceUUIDs: created, Endpoints: endpoints}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		glog.Errorln("Marshal ServiceTemplateDeploymentResponse error", err, "requuid", requuid)
+		return nil, merrors.Internal(err.Error())
+	}
+
+	return b, nil
+}
+
+// resolveTemplateOrder topologically sorts nodes so each node is created
+// only after the nodes it depends on, the same algorithm
+// resolveStackOrder uses for CatalogCreateStackRequest. Dependencies
+// outside the template are assumed to already exist and are not
+// validated here, as the template is not limited to catalog services.
+func (s *ManageHTTPServer) resolveTemplateOrder(nodes []*manage.ServiceTemplateServiceNode) ([]*manage.ServiceTemplateServiceNode, error) {
+	byName := make(map[string]*manage.ServiceTemplateServiceNode, len(nodes))
+	for _, n := range nodes {
+		byName[n.ServiceName] = n
+	}
+
+	visited := make(map[string]bool, len(nodes))
+	visiting := make(map[string]bool, len(nodes))
+	order := make([]*manage.ServiceTemplateServiceNode, 0, len(nodes))
+
+	var visit func(n *manage.ServiceTemplateServiceNode) error
+	visit = func(n *manage.ServiceTemplateServiceNode) error {
+		if visited[n.ServiceName] {
+			return nil
+		}
+		if visiting[n.ServiceName] {
+			return fmt.Errorf("circular service dependency at %s", n.ServiceName)
+		}
+		visiting[n.ServiceName] = true
+
+		for _, dep := range n.Dependencies {
+			if depNode, inTemplate := byName[dep.ServiceName]; inTemplate {
+				if err := visit(depNode); err != nil {
+					return err
+				}
+			}
+		}
+
+		visiting[n.ServiceName] = false
+		visited[n.ServiceName] = true
+		order = append(order, n)
+		return nil
+	}
+
+	for _, n := range nodes {
+		if err := visit(n); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// createTemplateNode creates one node of the template the same way
+// createService creates a standalone service: first in the control
+// plane, then in the container platform.
+func (s *ManageHTTPServer) createTemplateNode(ctx context.Context, node *manage.ServiceTemplateServiceNode, requuid string) (string, error) {
+	domain := dns.GenDefaultDomainName(s.cluster)
+	vpcID := s.serverInfo.GetLocalVpcID()
+
+	createReq := &manage.CreateServiceRequest{
+		Service: &manage.ServiceCommonRequest{
+			Region:      s.region,
+			Cluster:     s.cluster,
+			ServiceName: node.ServiceName,
+		},
+		ContainerImage: node.ContainerImage,
+		Replicas:       node.Replicas,
+		Resource:       node.Resource,
+	}
+
+	serviceUUID, err := s.svc.CreateService(ctx, createReq, domain, vpcID)
+	if err != nil {
+		return "", err
+	}
+
+	exist, err := s.containersvcIns.IsServiceExist(ctx, s.cluster, node.ServiceName)
+	if err != nil {
+		return "", err
+	}
+	if !exist {
+		opts := &containersvc.CreateServiceOptions{
+			Common: &containersvc.CommonOptions{
+				Cluster:        s.cluster,
+				ServiceName:    node.ServiceName,
+				ServiceUUID:    serviceUUID,
+				ContainerImage: node.ContainerImage,
+				Resource:       node.Resource,
+			},
+			Replicas: node.Replicas,
+		}
+		if err := s.containersvcIns.CreateService(ctx, opts); err != nil {
+			return "", err
+		}
+	}
+
+	glog.Infoln("created template node", node.ServiceName, serviceUUID, "requuid", requuid)
+	return serviceUUID, nil
+}
+
+// resolveEndpointIPs echoes the StaticIP of every endpoint of every node
+// back as its AssignedEndpoint, once the whole graph is created.
+// validateEndpointIPs already required StaticIP to be set and checked it
+// parses as a valid IPv4 or IPv6 address before deployTemplateWork got
+// this far, so there is nothing left to allocate here - this does not
+// draw from a pool or talk to the dns package, it only carries the
+// caller-supplied address into the response. resolveEndpointIPs is still
+// a separate pass from createTemplateNode, rather than folded into it,
+// because an endpoint may front a node another node depends on; it is
+// the right place to add pool-backed allocation for endpoints without a
+// StaticIP if a cluster-managed address pool is added later.
+func (s *ManageHTTPServer) resolveEndpointIPs(ctx context.Context, nodes []*manage.ServiceTemplateServiceNode, requuid string) (map[string][]manage.AssignedEndpoint, error) {
+	assigned := make(map[string][]manage.AssignedEndpoint, len(nodes))
+
+	for _, node := range nodes {
+		endpoints := make([]manage.AssignedEndpoint, 0, len(node.Endpoints))
+		for _, ep := range node.Endpoints {
+			endpoints = append(endpoints, manage.AssignedEndpoint{Name: ep.Name, IP: ep.StaticIP})
+		}
+		assigned[node.ServiceName] = endpoints
+		glog.Infoln("assigned endpoints", endpoints, "for", node.ServiceName, "requuid", requuid)
+	}
+
+	return assigned, nil
+}
+
+// rollbackTemplate deletes every service created earlier in a failed
+// ServiceTemplateDeploymentRequest call, best-effort, so a partial
+// deployment does not linger after the request reports an error.
+func (s *ManageHTTPServer) rollbackTemplate(ctx context.Context, created map[string]string, requuid string) {
+	for serviceName, serviceUUID := range created {
+		glog.Errorln("rolling back template service", serviceName, serviceUUID, "requuid", requuid)
+		if err := s.dbIns.DeleteService(ctx, s.cluster, serviceName); err != nil {
+			glog.Errorln("rollback DeleteService error", err, serviceName, serviceUUID, "requuid", requuid)
+		}
+	}
+}
+
+// listTemplates implements manage.ListTemplatesOp.
+func (s *ManageHTTPServer) listTemplates(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
+	req := &manage.ListTemplatesRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		glog.Errorln("ListTemplatesRequest decode error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	if req.Cluster != s.cluster || req.Region != s.region {
+		glog.Errorln("ListTemplatesRequest invalid request, local cluster", s.cluster,
+			"region", s.region, "requuid", requuid, req)
+		return merrors.BadRequest("cluster or region mismatch")
+	}
+
+	templates, err := s.dbIns.ListServiceTemplates(ctx, s.cluster)
+	if err != nil {
+		glog.Errorln("ListServiceTemplates error", err, "requuid", requuid)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	names := make([]string, len(templates))
+	for i, t := range templates {
+		names[i] = t.TemplateName
+	}
+
+	resp := &manage.ListTemplatesResponse{TemplateNames: names}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		glog.Errorln("Marshal ListTemplatesResponse error", err, "requuid", requuid)
+		return merrors.Internal(err.Error())
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+
+	return nil
+}
+
+// getTemplate implements manage.GetTemplateOp.
+func (s *ManageHTTPServer) getTemplate(ctx context.Context, w http.ResponseWriter, r *http.Request, requuid string) merrors.Error {
+	req := &manage.GetTemplateRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		glog.Errorln("GetTemplateRequest decode error", err, "requuid", requuid)
+		return merrors.BadRequest(err.Error())
+	}
+
+	if req.Cluster != s.cluster || req.Region != s.region {
+		glog.Errorln("GetTemplateRequest invalid request, local cluster", s.cluster,
+			"region", s.region, "requuid", requuid, req)
+		return merrors.BadRequest("cluster or region mismatch")
+	}
+
+	tmpl, err := s.dbIns.GetServiceTemplate(ctx, s.cluster, req.TemplateName)
+	if err != nil {
+		glog.Errorln("GetServiceTemplate error", err, "requuid", requuid, req.TemplateName)
+		return manage.ConvertToHTTPError(err)
+	}
+
+	resp := &manage.GetTemplateResponse{Template: tmpl.Request}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		glog.Errorln("Marshal GetTemplateResponse error", err, "requuid", requuid)
+		return merrors.Internal(err.Error())
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+
+	return nil
+}
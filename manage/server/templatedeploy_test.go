@@ -0,0 +1,60 @@
+package manageserver
+
+import (
+	"testing"
+
+	"github.com/cloudstax/firecamp/manage"
+)
+
+func templateNode(name string, deps ...string) *manage.ServiceTemplateServiceNode {
+	n := &manage.ServiceTemplateServiceNode{ServiceName: name}
+	for _, d := range deps {
+		n.Dependencies = append(n.Dependencies, manage.ServiceDependency{ServiceName: d})
+	}
+	return n
+}
+
+func TestResolveTemplateOrderTopoSort(t *testing.T) {
+	nodes := []*manage.ServiceTemplateServiceNode{
+		templateNode("kibana", "elasticsearch"),
+		templateNode("elasticsearch"),
+		templateNode("logstash", "elasticsearch"),
+	}
+
+	s := &ManageHTTPServer{}
+	order, err := s.resolveTemplateOrder(nodes)
+	if err != nil {
+		t.Fatalf("resolveTemplateOrder error: %v", err)
+	}
+	if len(order) != len(nodes) {
+		t.Fatalf("expected %d nodes in order, got %d", len(nodes), len(order))
+	}
+
+	pos := make(map[string]int, len(order))
+	for i, n := range order {
+		pos[n.ServiceName] = i
+	}
+	if pos["elasticsearch"] >= pos["kibana"] || pos["elasticsearch"] >= pos["logstash"] {
+		t.Errorf("expected elasticsearch before its dependents, got order %v", templateNames(order))
+	}
+}
+
+func TestResolveTemplateOrderDetectsCycle(t *testing.T) {
+	nodes := []*manage.ServiceTemplateServiceNode{
+		templateNode("a", "b"),
+		templateNode("b", "a"),
+	}
+
+	s := &ManageHTTPServer{}
+	if _, err := s.resolveTemplateOrder(nodes); err == nil {
+		t.Fatal("expected an error for a circular dependency, got nil")
+	}
+}
+
+func templateNames(nodes []*manage.ServiceTemplateServiceNode) []string {
+	out := make([]string, len(nodes))
+	for i, n := range nodes {
+		out[i] = n.ServiceName
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package manage
+
+import "github.com/cloudstax/firecamp/common"
+
+// ServiceDependency references another service that a catalog service
+// depends on, e.g. Kafka on ZooKeeper. For a CatalogCreateStackRequest the
+// manage server validates that every dependency outside the stack exists
+// and is common.ServiceStatusActive, and creates a node only after its
+// in-stack dependencies are active. A ServiceTemplateDeploymentRequest is
+// ordered by its dependencies, but dependencies outside the template are
+// not validated.
+type ServiceDependency struct {
+	ServiceName string
+	Role        string
+}
+
+// CatalogCreateStackServiceNode is one service in a CatalogCreateStackRequest
+// graph, e.g. one of Consul, Kafka or ZooKeeper in a Consul+Kafka+ZK stack.
+type CatalogCreateStackServiceNode struct {
+	ServiceName  string
+	ServiceType  string
+	Resource     *common.Resources
+	// Options carries the catalog-specific create options for ServiceType,
+	// e.g. *rediscatalog.CatalogCreateRedisOptions for CatalogService_Redis.
+	Options interface{}
+	// Dependencies may reference either another node in the same stack or
+	// a pre-existing service in the cluster.
+	Dependencies []ServiceDependency
+}
+
+// CatalogCreateStackRequest creates a graph of catalog services in
+// dependency order in a single call, rolling back the nodes already
+// created by this request if any node fails.
+type CatalogCreateStackRequest struct {
+	Region  string
+	Cluster string
+	Nodes   []*CatalogCreateStackServiceNode
+}
+
+// CatalogCreateStackResponse maps each created node's ServiceName to its
+// assigned ServiceUUID.
+type CatalogCreateStackResponse struct {
+	ServiceUUIDs map[string]string
+}
+
+// CatalogCreateStackOp is the special op ManageHTTPServer dispatches to
+// the stack creation handler.
+const CatalogCreateStackOp = SpecialOpPrefix + "CatalogCreateStack"
@@ -0,0 +1,105 @@
+package manage
+
+import "github.com/cloudstax/firecamp/common"
+
+// ServiceTemplateEndpoint is one network endpoint a templated service's
+// members expose, e.g. the client port ZooKeeper or Kafka listens on.
+// StaticIP must be a caller-supplied IPv4 or IPv6 address: there is no
+// cluster-managed address pool yet to allocate from, so every endpoint
+// has to be pinned explicitly.
+type ServiceTemplateEndpoint struct {
+	Name     string
+	Port     int64
+	Protocol string
+	StaticIP string
+}
+
+// ServiceTemplateVolumeSpec describes the volume for a templated service's
+// members, in the same shape CreateServiceRequest uses for a standalone
+// service. DeployTemplateOp does not pass it (nor a node's Envkvs) on when
+// it creates the node's service.
+type ServiceTemplateVolumeSpec struct {
+	VolumeType   string
+	VolumeSizeGB int64
+	Iops         int64
+}
+
+// ServiceTemplateServiceNode is one service in a
+// ServiceTemplateDeploymentRequest graph, e.g. one of ZooKeeper, Kafka and
+// Kibana in a logging stack template.
+type ServiceTemplateServiceNode struct {
+	ServiceName    string
+	ServiceType    string
+	ContainerImage string
+	Replicas       int64
+	Resource       *common.Resources
+	Endpoints      []ServiceTemplateEndpoint
+	Volume         *ServiceTemplateVolumeSpec
+	Envkvs         []*common.EnvKeyValuePair
+	// Dependencies may reference either another node in the same
+	// template or a pre-existing service in the cluster.
+	Dependencies []ServiceDependency
+}
+
+// ServiceTemplateDeploymentRequest deploys a multi-service application
+// graph in one call: the manage server walks Nodes in dependency order,
+// creating each with svc.CreateService + containersvcIns.CreateService,
+// then resolves every node's endpoint addresses with resolveEndpointIPs.
+// If any node fails, the services already created by this request are rolled
+// back, the same as CatalogCreateStackRequest.
+type ServiceTemplateDeploymentRequest struct {
+	Region       string
+	Cluster      string
+	TemplateName string
+	Nodes        []*ServiceTemplateServiceNode
+}
+
+// AssignedEndpoint is the address resolveEndpointIPs recorded for one
+// ServiceTemplateEndpoint.
+type AssignedEndpoint struct {
+	Name string
+	IP   string
+}
+
+// ServiceTemplateDeploymentResponse maps each created node's ServiceName
+// to its assigned ServiceUUID and the addresses resolveEndpointIPs gave
+// its endpoints.
+type ServiceTemplateDeploymentResponse struct {
+	ServiceUUIDs map[string]string
+	Endpoints    map[string][]AssignedEndpoint
+}
+
+// ListTemplatesRequest lists the application templates recorded for the
+// cluster.
+type ListTemplatesRequest struct {
+	Region  string
+	Cluster string
+}
+
+// ListTemplatesResponse returns the name of every recorded template; use
+// GetTemplateOp for the full node graph of one.
+type ListTemplatesResponse struct {
+	TemplateNames []string
+}
+
+// GetTemplateRequest asks for the full node graph of one recorded
+// template.
+type GetTemplateRequest struct {
+	Region       string
+	Cluster      string
+	TemplateName string
+}
+
+// GetTemplateResponse returns the deployment request the template was
+// originally deployed with, so it can be resubmitted to DeployTemplateOp.
+type GetTemplateResponse struct {
+	Template *ServiceTemplateDeploymentRequest
+}
+
+// DeployTemplateOp, ListTemplatesOp and GetTemplateOp are the special ops
+// ManageHTTPServer dispatches to the template deployment handlers.
+const (
+	DeployTemplateOp = SpecialOpPrefix + "DeployTemplate"
+	ListTemplatesOp  = SpecialOpPrefix + "ListTemplates"
+	GetTemplateOp    = SpecialOpPrefix + "GetTemplate"
+)
@@ -0,0 +1,227 @@
+package operations
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/cloudstax/firecamp/utils"
+)
+
+// Status is the lifecycle state of an Operation.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSuccess   Status = "success"
+	StatusError     Status = "error"
+	StatusCancelled Status = "cancelled"
+)
+
+// IsTerminal reports whether status is one WaitOperationOp should stop
+// blocking on.
+func (s Status) IsTerminal() bool {
+	return s == StatusSuccess || s == StatusError || s == StatusCancelled
+}
+
+// Operation is the record of one long-running async call (create/delete
+// service, run task, deploy template). Manager mirrors every transition
+// to dbIns, so GetOperationOp/ListOperationsOp/WaitOperationOp survive a
+// manage server restart whenever dbIns itself is a durable store; cancel
+// and done are always process-local and are lost on restart regardless,
+// same as any other in-flight goroutine.
+type Operation struct {
+	ID           string
+	Cluster      string
+	Status       Status
+	Progress     string
+	ResourceURLs []string
+	// Result is the response body the underlying handler would have
+	// written synchronously, so a WaitOperationOp/GetOperationOp caller
+	// gets the same payload a synchronous call would have returned.
+	Result      json.RawMessage
+	ErrMsg      string
+	CreatedTime int64
+	UpdatedTime int64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// DBOps is the state store Manager mirrors every Operation transition to.
+// It is shaped like the subset of db.DB a durable implementation would
+// add, but db.DB does not implement it in this build, so the caller
+// constructing a Manager supplies its own; see operationStore in
+// manage/server for the in-memory one this build actually uses.
+type DBOps interface {
+	CreateOperation(ctx context.Context, op *Operation) error
+	UpdateOperation(ctx context.Context, op *Operation) error
+	GetOperation(ctx context.Context, id string) (*Operation, error)
+	ListOperations(ctx context.Context, cluster string) ([]*Operation, error)
+}
+
+// Manager tracks in-flight and completed Operations in memory, mirroring
+// every transition to dbIns.
+type Manager struct {
+	dbIns DBOps
+
+	mu  sync.Mutex
+	ops map[string]*Operation
+}
+
+// NewManager creates a Manager mirroring Operation state through dbIns.
+func NewManager(dbIns DBOps) *Manager {
+	return &Manager{dbIns: dbIns, ops: make(map[string]*Operation)}
+}
+
+// Start creates a new pending Operation for resourceURL, scoped to
+// cluster, and derives a cancelable context from parent. The caller
+// launches its goroutine with the returned context, so CancelOperationOp
+// propagates to whatever containersvc/db/dns calls that goroutine is
+// blocked on.
+func (m *Manager) Start(parent context.Context, cluster string, resourceURL string) (*Operation, context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+	now := time.Now().UnixNano()
+	op := &Operation{
+		ID:           utils.GenRequestUUID(),
+		Cluster:      cluster,
+		Status:       StatusPending,
+		ResourceURLs: []string{resourceURL},
+		CreatedTime:  now,
+		UpdatedTime:  now,
+		cancel:       cancel,
+		done:         make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.ops[op.ID] = op
+	m.mu.Unlock()
+
+	if err := m.dbIns.CreateOperation(parent, op); err != nil {
+		glog.Errorln("CreateOperation error", err, op.ID)
+	}
+
+	return op, ctx
+}
+
+// SetRunning marks op running, mirroring the transition to dbIns.
+func (m *Manager) SetRunning(op *Operation) {
+	m.mu.Lock()
+	op.Status = StatusRunning
+	op.UpdatedTime = time.Now().UnixNano()
+	snapshot := copyOp(op)
+	m.mu.Unlock()
+
+	if err := m.dbIns.UpdateOperation(context.Background(), snapshot); err != nil {
+		glog.Errorln("UpdateOperation error", err, op.ID)
+	}
+}
+
+// Finish marks op success, error or cancelled depending on runErr,
+// records result as the response body a synchronous call would have
+// returned, mirrors the final state to dbIns, and closes op's done
+// channel so any blocked WaitOperationOp call wakes up.
+func (m *Manager) Finish(op *Operation, result json.RawMessage, runErr error) {
+	m.mu.Lock()
+	op.Status = StatusSuccess
+	if runErr != nil {
+		op.Status = StatusError
+		op.ErrMsg = runErr.Error()
+		if runErr == context.Canceled {
+			op.Status = StatusCancelled
+		}
+	}
+	op.Result = result
+	op.UpdatedTime = time.Now().UnixNano()
+	snapshot := copyOp(op)
+	m.mu.Unlock()
+
+	if err := m.dbIns.UpdateOperation(context.Background(), snapshot); err != nil {
+		glog.Errorln("UpdateOperation error", err, op.ID)
+	}
+
+	close(op.done)
+}
+
+// Get returns a point-in-time copy of the Operation for id, falling back
+// to dbIns if the process restarted since id was created.
+func (m *Manager) Get(ctx context.Context, id string) (*Operation, error) {
+	m.mu.Lock()
+	op, ok := m.ops[id]
+	if ok {
+		defer m.mu.Unlock()
+		return copyOp(op), nil
+	}
+	m.mu.Unlock()
+
+	return m.dbIns.GetOperation(ctx, id)
+}
+
+// List returns every Operation recorded for cluster, preferring the
+// in-memory copy over dbIns's for any operation still tracked by this
+// process.
+func (m *Manager) List(ctx context.Context, cluster string) ([]*Operation, error) {
+	stored, err := m.dbIns.ListOperations(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ops := make([]*Operation, len(stored))
+	for i, op := range stored {
+		if live, ok := m.ops[op.ID]; ok {
+			ops[i] = copyOp(live)
+			continue
+		}
+		ops[i] = op
+	}
+	return ops, nil
+}
+
+// Cancel calls the cancel func of a still in-flight operation, so the
+// goroutine's containersvc/db/dns calls observe ctx.Done() and stop
+// promptly. It is a no-op, not an error, for an operation that already
+// finished or was created before a manage server restart.
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	op, ok := m.ops[id]
+	m.mu.Unlock()
+
+	if ok && op.cancel != nil {
+		op.cancel()
+	}
+	return nil
+}
+
+// Wait blocks until the operation for id reaches a terminal status or
+// timeout elapses, whichever comes first, and returns its state at that
+// point either way.
+func (m *Manager) Wait(ctx context.Context, id string, timeout time.Duration) (*Operation, error) {
+	m.mu.Lock()
+	op, ok := m.ops[id]
+	m.mu.Unlock()
+	if !ok {
+		return m.dbIns.GetOperation(ctx, id)
+	}
+
+	select {
+	case <-op.done:
+	case <-time.After(timeout):
+	case <-ctx.Done():
+	}
+
+	return m.Get(ctx, id)
+}
+
+func copyOp(op *Operation) *Operation {
+	cp := *op
+	cp.cancel = nil
+	cp.done = nil
+	return &cp
+}
@@ -0,0 +1,29 @@
+package templatestore
+
+import "github.com/cloudstax/firecamp/manage"
+
+// ServiceTemplate is the durable record of a multi-service application
+// graph, written by DeployTemplateOp before any node is created so
+// ListTemplatesOp/GetTemplateOp can serve it back even if the deployment
+// itself later fails partway through, and so the caller can fetch the
+// same graph and resubmit it to DeployTemplateOp.
+type ServiceTemplate struct {
+	Region       string
+	Cluster      string
+	TemplateName string
+	Request      *manage.ServiceTemplateDeploymentRequest
+	CreatedTime  int64
+}
+
+// NewServiceTemplate builds the durable ServiceTemplate record for req,
+// for the manage server to persist via dbIns.CreateServiceTemplate before
+// walking req.Nodes.
+func NewServiceTemplate(req *manage.ServiceTemplateDeploymentRequest, createdTime int64) *ServiceTemplate {
+	return &ServiceTemplate{
+		Region:       req.Region,
+		Cluster:      req.Cluster,
+		TemplateName: req.TemplateName,
+		Request:      req,
+		CreatedTime:  createdTime,
+	}
+}